@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+)
+
+// assumeResult holds the outcome of assuming one of the roles
+type assumeResult struct {
+	role     *masl.SAMLAssertionRole
+	profiles []string
+	output   *sts.AssumeRoleWithSAMLOutput
+	err      error
+}
+
+// assumeAll assumes all roles concurrently with the same SAML assertion and stores the credentials
+// of each role in the profiles of its account
+func assumeAll(samlData string, conf masl.Config, roles []*masl.SAMLAssertionRole, flags Flags) error {
+	usr, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	results := make([]assumeResult, len(roles))
+	var wg sync.WaitGroup
+	for index, role := range roles {
+		wg.Add(1)
+		go func(index int, role *masl.SAMLAssertionRole) {
+			defer wg.Done()
+			output, err := masl.AssumeRole(conf, samlData, masl.SessionDuration(conf, role), role)
+			results[index] = assumeResult{role: role, profiles: allProfileNames(conf, role, roles),
+				output: output, err: err}
+		}(index, role)
+	}
+	wg.Wait()
+
+	// The credentials file is written sequentially
+	failed := 0
+	for index := range results {
+		result := &results[index]
+		for _, profile := range result.profiles {
+			if result.err != nil {
+				break
+			}
+			result.err = storeCredentials(result.output, usr.HomeDir, conf, result.role, profile,
+				legacyToken(conf, result.role, flags))
+		}
+		if result.err != nil {
+			failed++
+			logger.Warn(result.err.Error())
+			continue
+		}
+		if !conf.DisableCache {
+			cacheCredentials(result.output, result.role)
+		}
+	}
+	logger.Sugar().Infof("w00t w00t masl for you!, Assumed %d of %d roles.", len(roles)-failed, len(roles))
+
+	fmt.Fprintln(out, "\nw00t w00t masl for you!")
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ACCOUNT\tNAME\tROLE\tPROFILE\tRESULT")
+	for _, result := range results {
+		var status string
+		if result.err != nil {
+			status = fmt.Sprintf("\033[1;31m%s\033[0m", result.err)
+		} else {
+			status = fmt.Sprintf("\033[1;32mexpires %v\033[0m", *result.output.Credentials.Expiration)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", result.role.AccountID, result.role.AccountName,
+			result.role.RoleName, strings.Join(result.profiles, ", "), status)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d roles could not be assumed", masl.ErrSTSDenied, failed, len(roles))
+	}
+	return nil
+}
+
+// allProfileNames returns the profiles of a role: the profiles configured for its account, like for a
+// single role, or else its account name (its account ID for accounts without a name). The role name is
+// added when multiple roles of the account are assumed.
+func allProfileNames(conf masl.Config, role *masl.SAMLAssertionRole, roles []*masl.SAMLAssertionRole) []string {
+	names := accountProfiles(conf, role)
+	if len(names) == 0 {
+		name := role.AccountName
+		if _, found := masl.GetAccount(conf, role.AccountID); !found {
+			name = role.AccountID
+		}
+		names = []string{name}
+	}
+	for _, other := range roles {
+		if other != role && other.AccountID == role.AccountID {
+			suffixed := make([]string, len(names))
+			for index, name := range names {
+				suffixed[index] = name + "-" + role.RoleName
+			}
+			return suffixed
+		}
+	}
+	return names
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/glnds/masl/internal/masl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllProfileNames(t *testing.T) {
+	conf := masl.Config{Accounts: masl.Accounts{
+		{ID: "111111111111", Name: "prod"},
+		{ID: "222222222222", Name: "sandbox", Profiles: []string{"sandbox", "playground"}},
+	}}
+	prodAdmin := &masl.SAMLAssertionRole{AccountID: "111111111111", AccountName: "prod", RoleName: "admin"}
+	prodReader := &masl.SAMLAssertionRole{AccountID: "111111111111", AccountName: "prod", RoleName: "reader"}
+	sandboxAdmin := &masl.SAMLAssertionRole{AccountID: "222222222222", AccountName: "sandbox", RoleName: "admin"}
+	sandboxReader := &masl.SAMLAssertionRole{AccountID: "222222222222", AccountName: "sandbox",
+		RoleName: "reader"}
+	unknown := &masl.SAMLAssertionRole{AccountID: "333333333333", AccountName: "untitled", RoleName: "admin"}
+
+	tests := []struct {
+		name     string
+		role     *masl.SAMLAssertionRole
+		roles    []*masl.SAMLAssertionRole
+		profiles []string
+	}{
+		{"account name", prodAdmin, []*masl.SAMLAssertionRole{prodAdmin, sandboxAdmin}, []string{"prod"}},
+		{"role suffix", prodAdmin, []*masl.SAMLAssertionRole{prodAdmin, prodReader}, []string{"prod-admin"}},
+		{"account id", unknown, []*masl.SAMLAssertionRole{unknown}, []string{"333333333333"}},
+		{"configured profiles", sandboxAdmin, []*masl.SAMLAssertionRole{sandboxAdmin, prodAdmin},
+			[]string{"sandbox", "playground"}},
+		{"configured profiles with role suffix", sandboxReader,
+			[]*masl.SAMLAssertionRole{sandboxAdmin, sandboxReader}, []string{"sandbox-reader", "playground-reader"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.profiles, allProfileNames(conf, test.role, test.roles), test.name)
+	}
+
+	// A single role is stored in the same configured profiles
+	assert.Equal(t, []string{"sandbox", "playground"}, profileNames(conf, sandboxAdmin, Flags{Profile: "default"}))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+)
+
+const consoleCommand = "console"
+
+// console prints, or opens in the browser, a URL to sign in to the AWS Management Console
+func console(assertionOutput *sts.AssumeRoleWithSAMLOutput, conf masl.Config, role *masl.SAMLAssertionRole,
+	flags Flags) error {
+
+	region := flags.Region
+	if region == "" {
+		region = masl.Region(conf, role)
+	}
+	url, err := masl.ConsoleURL(conf, assertionOutput, role, flags.Destination, region)
+	if err != nil {
+		return err
+	}
+	logger.Sugar().Infof("w00t w00t masl for you!, Console sign-in URL created for account [%s].", role.AccountID)
+
+	if !flags.Open {
+		fmt.Println(url)
+		return nil
+	}
+	fmt.Fprintln(out, "\nw00t w00t masl for you!")
+	fmt.Fprintf(out, "Opening the AWS console as %s in account: %v [%v]\n", role.RoleName, role.AccountID,
+		role.AccountName)
+	if err := openBrowser(url); err != nil {
+		logger.Warn(err.Error())
+		fmt.Fprintf(out, "Unable to open your browser, sign in with:\n%s\n", url)
+	}
+	return nil
+}
+
+// openBrowser opens a URL in the user's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
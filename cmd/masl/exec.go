@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+)
+
+const execCommand = "exec"
+
+// errNoCommand exec was started without a command to run
+var errNoCommand = errors.New("no command given, usage: masl exec [flags] -- command [args...]")
+
+// forwardedSignals the signals forwarded to the command. A Ctrl-C or Ctrl-\ in the terminal reaches the
+// command directly as well, but a SIGINT or SIGQUIT sent by a supervisor only reaches masl.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// execute runs the command given after the flags with the STS credentials in its environment.
+// masl waits for the command, the forwardedSignals are passed on to it.
+func execute(assertionOutput *sts.AssumeRoleWithSAMLOutput, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
+
+	if len(flags.Args) == 0 {
+		return errNoCommand
+	}
+
+	cmd := exec.Command(flags.Args[0], flags.Args[1:]...) // #nosec
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = credentialEnviron(os.Environ(),
+		masl.CredentialEnv(assertionOutput, masl.Region(conf, role)))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	logger.Sugar().Infof("Started [%s] with the credentials of account [%s].", flags.Args[0], role.AccountID)
+
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+	return cmd.Wait()
+}
+
+// commandExitCode returns the exit code of a command, 128 plus the signal number when a signal
+// killed it like a shell does
+func commandExitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// credentialEnviron replaces the AWS credentials and profile in an environment
+func credentialEnviron(environ []string, vars []masl.EnvVar) []string {
+	replaced := map[string]bool{"AWS_PROFILE": true, "AWS_DEFAULT_PROFILE": true,
+		"AWS_SECURITY_TOKEN": true}
+	for _, envVar := range vars {
+		replaced[envVar.Name] = true
+	}
+
+	result := []string{}
+	for _, entry := range environ {
+		name := strings.SplitN(entry, "=", 2)[0]
+		if !replaced[strings.ToUpper(name)] {
+			result = append(result, entry)
+		}
+	}
+	for _, envVar := range vars {
+		result = append(result, envVar.Name+"="+envVar.Value)
+	}
+	return result
+}
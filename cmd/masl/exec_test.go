@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/glnds/masl/internal/masl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialEnviron(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "AWS_PROFILE=prod", "aws_default_profile=prod",
+		"AWS_SECURITY_TOKEN=old", "AWS_ACCESS_KEY_ID=OLD", "HOME=/home/user"}
+	vars := []masl.EnvVar{{Name: "AWS_ACCESS_KEY_ID", Value: "AKID"},
+		{Name: "AWS_SESSION_TOKEN", Value: "TOKEN"}}
+
+	assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/user", "AWS_ACCESS_KEY_ID=AKID",
+		"AWS_SESSION_TOKEN=TOKEN"}, credentialEnviron(environ, vars))
+}
+
+func TestCommandExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	tests := []struct {
+		script string
+		code   int
+	}{
+		{"exit 3", 3},
+		{"kill -TERM $$", 143},
+		{"kill -INT $$", 130},
+	}
+	for _, test := range tests {
+		err := exec.Command("sh", "-c", test.script).Run()
+		var exitErr *exec.ExitError
+		if assert.True(t, errors.As(err, &exitErr), test.script) {
+			assert.Equal(t, test.code, commandExitCode(exitErr), test.script)
+		}
+	}
+}
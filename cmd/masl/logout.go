@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+
+	"github.com/glnds/masl/internal/masl"
+)
+
+const logoutCommand = "logout"
+
+// logout removes the cached credentials and revokes the cached OneLogin API token
+func logout(conf masl.Config) error {
+	usr, err := user.Current()
+	if err != nil {
+		return err
+	}
+	if err := masl.ClearCache(usr.HomeDir); err != nil {
+		return err
+	}
+	clientSecret, err := masl.ClientSecret(conf, secretStore(conf))
+	if err != nil {
+		return err
+	}
+	conf.ClientSecret = clientSecret
+	if err := masl.RevokeToken(conf, usr.HomeDir); err != nil {
+		return err
+	}
+	logger.Info("Logged out, cached credentials and API token removed.")
+	fmt.Fprintln(out, "Logged out, the cached credentials and OneLogin API token are removed.")
+	return nil
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"os/user"
+	"sort"
 	"syscall"
 
 	"bufio"
@@ -10,7 +14,9 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/glnds/masl/internal/masl"
 	"go.uber.org/zap"
 	"golang.org/x/term"
@@ -20,19 +26,59 @@ var logger *zap.Logger
 
 var version, build, commit, date string
 
+// out receives all user facing messages. Modes which print a machine readable document on
+// stdout redirect it to stderr.
+var out io.Writer = os.Stdout
+
+const credentialProcessCommand = "credential-process"
+
+// outputEnv prints the credentials as environment variables instead of storing them
+const outputEnv = "env"
+
 // Flags represents the command line flags
 type Flags struct {
-	Version     bool
-	LegacyToken bool
-	Profile     string
-	Env         string
-	Account     string
-	Role        string
+	Command        string
+	Version        bool
+	LegacyToken    bool
+	LegacyTokenSet bool
+	Profile        string
+	ProfileSet     bool
+	Env            string
+	Account        string
+	Role           string
+	NoCache        bool
+	All            bool
+	Last           bool
+	Output         string
+	Shell          string
+	Address        string
+	Open           bool
+	Destination    string
+	Region         string
+	Args           []string
 }
 
+// Exit codes, one for every category of error so wrapper scripts can react on them
+const (
+	exitError              = 1
+	exitConfig             = 2
+	exitInvalidCredentials = 3
+	exitMFA                = 4
+	exitNoRoles            = 5
+	exitSTSDenied          = 6
+)
+
 func main() {
 
-	conf := masl.GetConfig()
+	command, args := parseCommand(os.Args[1:])
+	if command == credentialProcessCommand || command == execCommand || command == consoleCommand {
+		out = os.Stderr
+	}
+
+	conf, err := masl.GetConfig()
+	if err != nil {
+		exit(err)
+	}
 	if conf.Debug {
 		logger = masl.GetLogger("debug")
 	} else {
@@ -41,88 +87,392 @@ func main() {
 
 	logger.Info("------------------ w00t w00t masl for you!?  ------------------")
 
-	flags := parseFlags(conf)
+	flags := parseFlags(conf, command, args)
 	logger.Info("Parsed the commandline flags")
+	if flags.Output == outputEnv {
+		out = os.Stderr
+	}
+
+	if err := run(conf, flags); err != nil {
+		exit(err)
+	}
+}
+
+func run(conf masl.Config, flags Flags) error {
+	if flags.Output != "" && flags.Output != outputEnv {
+		return fmt.Errorf("unsupported output [%s], only '%s' is supported", flags.Output, outputEnv)
+	}
+	if flags.Command == secretCommand {
+		return manageSecret(conf, flags.Args)
+	}
+	if flags.Command == logoutCommand {
+		return logout(conf)
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return err
+	}
+	if flags.All && (flags.Command != "" || flags.Output != "") {
+		return errors.New("-all can only be used to store the credentials in profiles")
+	}
+	if flags.Last {
+		last, found := masl.LastRole(usr.HomeDir)
+		if !found {
+			return fmt.Errorf("%w: no previously selected role", masl.ErrNoRoles)
+		}
+		flags.Account = last.AccountID
+		flags.Role = last.RoleName
+	}
+	if !flags.NoCache && !conf.DisableCache && !flags.All && flags.Account != "" {
+		// Only a known role can be taken from the cache, otherwise the role is picked from the SAML assertion
+		account := accountID(conf, flags)
+		role, assertionOutput := masl.CachedCredentials(usr.HomeDir, account,
+			masl.ResolveRole(conf, account, flags.Role), time.Duration(conf.CacheMinLifetime)*time.Second)
+		if assertionOutput != nil {
+			recordRole(role)
+			return useCredentials(assertionOutput, "", conf, role, flags)
+		}
+	}
+
+	password, err := readPassword(conf)
+	if err != nil {
+		return err
+	}
+	return DoMasl(conf, flags, password)
+}
 
+// readPassword returns the OneLogin password from the PASSWORD environment variable, the
+// PasswordCommand or the secret store, or asks for it
+func readPassword(conf masl.Config) (string, error) {
 	password := os.Getenv("PASSWORD")
 	if password == "" {
-		// Ask for the user's password
-		fmt.Print("OneLogin Password: ")
-		bytePassword, _ := term.ReadPassword(int(syscall.Stdin)) // nolint
-		password = string(bytePassword)
+		var err error
+		if password, err = masl.Password(conf, secretStore(conf)); err != nil {
+			return "", err
+		}
+	}
+	if password == "" {
+		password = promptPassword()
 	}
+	return password, nil
+}
+
+// promptPassword asks for the user's password
+func promptPassword() string {
+	fmt.Fprint(out, "OneLogin Password: ")
+	bytePassword, _ := term.ReadPassword(int(syscall.Stdin)) // nolint
+	return string(bytePassword)
+}
 
-	DoMasl(conf, flags, password)
+// interactive test if the user can be asked to retry
+func interactive() bool {
+	return term.IsTerminal(int(syscall.Stdin))
+}
+
+// exit reports the error and terminates masl with the exit code matching the error
+func exit(err error) {
+	// The command started by exec reports its own errors
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := commandExitCode(exitErr); code > 0 {
+			os.Exit(code)
+		}
+		os.Exit(exitError)
+	}
+
+	fmt.Fprintf(out, "\n%s\n", err)
+	if logger != nil {
+		logger.Error(err.Error())
+	}
+
+	code := exitError
+	switch {
+	case errors.Is(err, masl.ErrConfigMissing), errors.Is(err, masl.ErrInvalidConfig),
+		errors.Is(err, masl.ErrAPIToken):
+		code = exitConfig
+	case errors.Is(err, masl.ErrInvalidCredentials), errors.Is(err, masl.ErrAccountLocked):
+		code = exitInvalidCredentials
+	case errors.Is(err, masl.ErrMFARejected), errors.Is(err, masl.ErrMFATimeout),
+		errors.Is(err, masl.ErrStateTokenExpired):
+		code = exitMFA
+	case errors.Is(err, masl.ErrNoRoles):
+		code = exitNoRoles
+	case errors.Is(err, masl.ErrSTSDenied), errors.Is(err, masl.ErrFederation):
+		code = exitSTSDenied
+	}
+	os.Exit(code)
 }
 
 // DoMasl Allow other tools to integrate with Masl to assume an AWS role
-func DoMasl(conf masl.Config, flags Flags, password string) {
-	accountFilter := initAccountFilter(conf, flags)
-	// Generate a new OneLogin API token
-	apiToken := masl.GenerateToken(conf)
+func DoMasl(conf masl.Config, flags Flags, password string) error {
+	if flags.All {
+		samlData, roles, err := authenticate(conf, flags, password)
+		if err != nil {
+			return err
+		}
+		return assumeAll(samlData, conf, roles, flags)
+	}
 
-	// OneLogin SAML assertion API call
-	samlAssertionData, err := masl.SAMLAssertion(conf, password, apiToken)
+	samlData, role, err := login(conf, flags, password)
+	if err != nil {
+		return err
+	}
+	assertionOutput, err := masl.AssumeRole(conf, samlData, masl.SessionDuration(conf, role), role)
 	if err != nil {
-		fmt.Printf("\n%s\n", err)
-		logger.Fatal(err.Error())
+		return err
+	}
+	if !conf.DisableCache {
+		cacheCredentials(assertionOutput, role)
 	}
+	return useCredentials(assertionOutput, samlData, conf, role, flags)
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	samlData := readSamlData(samlAssertionData, conf, reader, apiToken)
+// login authenticates on OneLogin and returns the SAML assertion and the selected role
+func login(conf masl.Config, flags Flags, password string) (string, *masl.SAMLAssertionRole, error) {
+	samlData, roles, err := authenticate(conf, flags, password)
+	if err != nil {
+		return "", nil, err
+	}
+	role, err := selectRole(roles)
+	if err != nil {
+		return "", nil, err
+	}
+	recordRole(role)
+	return samlData, role, nil
+}
+
+// authenticate authenticates on OneLogin and returns the SAML assertion and the available roles
+func authenticate(conf masl.Config, flags Flags, password string) (string, []*masl.SAMLAssertionRole, error) {
+	accountFilter := initAccountFilter(conf, flags)
+	clientSecret, err := masl.ClientSecret(conf, secretStore(conf))
+	if err != nil {
+		return "", nil, err
+	}
+	conf.ClientSecret = clientSecret
+
+	samlData, err := samlAssertion(conf, flags, password)
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Print all SAMLAssertion Roles
-	roles := masl.ParseSAMLAssertion(samlData, conf.Accounts, accountFilter, flags.Role)
+	role := flags.Role
+	if flags.Account != "" && !(flags.All && flags.Role == "") {
+		// Resolve an alias or, unless all roles are assumed, the default role of the account
+		role = masl.ResolveRole(conf, accountID(conf, flags), flags.Role)
+	}
+	roles, err := masl.ParseSAMLAssertion(samlData, conf.Accounts, accountFilter, role)
+	if err == nil && len(roles) == 0 && flags.Role == "" && role != "" {
+		// The default role isn't available, offer all roles of the account
+		logger.Sugar().Infof("Default role [%s] not available.", role)
+		roles, err = masl.ParseSAMLAssertion(samlData, conf.Accounts, accountFilter, "")
+	}
+	var malformed *masl.MalformedRoleError
+	if errors.As(err, &malformed) {
+		fmt.Fprintf(out, "\033[1;33m[WARNING] %s\033[0m\n", malformed)
+	} else if err != nil {
+		return "", nil, err
+	}
 	if len(roles) == 0 {
-		fmt.Println("No  masl for you! You don't have permissions to any account!")
-		os.Exit(0)
+		return "", nil, fmt.Errorf("%w: No  masl for you! You don't have permissions to any account!", masl.ErrNoRoles)
 	}
-	role := selectRole(roles)
-	awsAuthenticate(samlData, conf, role, flags)
-}
-
-func readSamlData(samlAssertionData masl.SAMLAssertionData, conf masl.Config, reader *bufio.Reader, apiToken string) string {
-	var samlData string
-	var err error
-	if samlAssertionData.MFARequired {
-		fmt.Print("\n")
-		device := selectMFADevice(samlAssertionData.Devices, conf.DefaulMFADevice)
-		otp := os.Getenv("OTP")
-		if otp == "" {
-			// Ask for a new otp
-			if strings.Contains(strings.ToLower(device.DeviceType), "yubikey") {
-				fmt.Printf("Enter your YubiKey security code: ")
-			} else {
-				fmt.Printf("Enter your %s one-time password: ", device.DeviceType)
-			}
-			otp, _ = reader.ReadString('\n')
+	return samlData, roles, nil
+}
+
+// useCredentials hands the STS credentials over to the selected output mode
+func useCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, samlData string, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
+
+	switch {
+	case flags.Command == credentialProcessCommand:
+		return credentialProcess(assertionOutput, role)
+	case flags.Command == execCommand:
+		return execute(assertionOutput, conf, role, flags)
+	case flags.Command == serveCommand:
+		return serve(assertionOutput, samlData, conf, role, flags)
+	case flags.Command == imdsCommand:
+		return serveIMDS(assertionOutput, samlData, conf, role, flags)
+	case flags.Command == consoleCommand:
+		return console(assertionOutput, conf, role, flags)
+	case flags.Output == outputEnv:
+		return printEnv(assertionOutput, conf, role, flags)
+	default:
+		return awsAuthenticate(assertionOutput, samlData, conf, role, flags)
+	}
+}
+
+// recordRole adds the selected role to the history, offering it first the next time
+func recordRole(role *masl.SAMLAssertionRole) {
+	usr, err := user.Current()
+	if err != nil {
+		logger.Warn(err.Error())
+		return
+	}
+	if err := masl.RecordRole(usr.HomeDir, role); err != nil {
+		logger.Warn(err.Error())
+	}
+}
+
+func cacheCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, role *masl.SAMLAssertionRole) {
+	usr, err := user.Current()
+	if err != nil {
+		logger.Warn(err.Error())
+		return
+	}
+	// Failing to cache the credentials shouldn't prevent them from being used
+	if err := masl.CacheCredentials(usr.HomeDir, role, assertionOutput); err != nil {
+		logger.Warn(err.Error())
+	}
+}
+
+// requestSAMLAssertion requests the SAML assertion and returns the OneLogin API token used. Without a token
+// in use yet, the cached token is used unless caching is disabled.
+func requestSAMLAssertion(conf masl.Config, flags Flags, password string,
+	apiToken string) (masl.SAMLAssertionData, string, error) {
+
+	if apiToken == "" && !flags.NoCache && !conf.DisableCache {
+		usr, err := user.Current()
+		if err != nil {
+			return masl.SAMLAssertionData{}, "", err
+		}
+		return masl.CachedSAMLAssertion(conf, usr.HomeDir, password)
+	}
+	if apiToken == "" {
+		var err error
+		if apiToken, err = masl.GenerateToken(conf); err != nil {
+			return masl.SAMLAssertionData{}, "", err
+		}
+	}
+	samlAssertionData, err := masl.SAMLAssertion(conf, password, apiToken)
+	return samlAssertionData, apiToken, err
+}
+
+// samlAssertion requests the SAML assertion and verifies the MFA device. A rejected password is asked
+// again and an expired state token is replaced by requesting a new assertion, up to LoginAttempts times.
+func samlAssertion(conf masl.Config, flags Flags, password string) (string, error) {
+	attempts := masl.LoginAttempts(conf)
+	reader := bufio.NewReader(os.Stdin)
+	var apiToken string
+	for attempt := 1; ; attempt++ {
+		// OneLogin SAML assertion API call
+		samlAssertionData, token, err := requestSAMLAssertion(conf, flags, password, apiToken)
+		if token != "" {
+			apiToken = token
+		}
+		if errors.Is(err, masl.ErrInvalidCredentials) && attempt < attempts && interactive() {
+			fmt.Fprintf(out, "\n%s, please try again.\n", err)
+			password = promptPassword()
+			continue
 		}
-		samlData, err = masl.VerifyMFA(conf, device.DeviceID, samlAssertionData.StateToken,
-			otp, apiToken)
-		// OneLogin Verify MFA API call
 		if err != nil {
-			fmt.Println(err)
-			logger.Fatal(err.Error())
+			return "", err
+		}
+
+		samlData, err := readSamlData(samlAssertionData, conf, reader, apiToken)
+		if errors.Is(err, masl.ErrStateTokenExpired) && attempt < attempts {
+			logger.Info(err.Error())
+			fmt.Fprintln(out, "The MFA session expired, requesting a new one.")
+			continue
 		}
+		return samlData, err
+	}
+}
+
+func readSamlData(samlAssertionData masl.SAMLAssertionData, conf masl.Config, reader *bufio.Reader,
+	apiToken string) (string, error) {
+	if !samlAssertionData.MFARequired {
+		fmt.Fprintln(out)
+		return samlAssertionData.Data, nil
+	}
+
+	fmt.Fprint(out, "\n")
+	device, err := selectMFADevice(samlAssertionData.Devices, conf.DefaulMFADevice)
+	if err != nil {
+		return "", err
+	}
+	otp := os.Getenv("OTP")
+	if otp == "" && masl.UseTOTP(conf, device) {
+		// Generate the one-time password when its seed is available, before falling back to push
+		seed, err := masl.OTPSeed(conf, secretStore(conf))
+		if err != nil {
+			return "", err
+		}
+		if seed != "" {
+			return masl.VerifyMFATOTP(conf, device.DeviceID, samlAssertionData.StateToken, seed, apiToken)
+		}
+	}
+	if otp == "" && masl.IsPushDevice(device) {
+		return verifyPush(samlAssertionData, conf, device, apiToken)
+	}
+	prompted := otp == ""
+	if prompted {
+		otp = promptOTP(device, reader)
+	}
+	attempts := masl.LoginAttempts(conf)
+	for attempt := 1; ; attempt++ {
+		// OneLogin Verify MFA API call
+		samlData, err := masl.VerifyMFA(conf, device.DeviceID, samlAssertionData.StateToken, otp, apiToken)
+		if !errors.Is(err, masl.ErrMFARejected) || !prompted || attempt >= attempts {
+			return samlData, err
+		}
+		fmt.Fprintf(out, "%s, please try again.\n", err)
+		otp = promptOTP(device, reader)
+	}
+}
+
+// promptOTP asks for a one-time password of the MFA device
+func promptOTP(device masl.MFADevice, reader *bufio.Reader) string {
+	if strings.Contains(strings.ToLower(device.DeviceType), "yubikey") {
+		fmt.Fprintf(out, "Enter your YubiKey security code: ")
 	} else {
-		fmt.Println()
-		samlData = samlAssertionData.Data
+		fmt.Fprintf(out, "Enter your %s one-time password: ", device.DeviceType)
 	}
-	return samlData
+	otp, _ := reader.ReadString('\n')
+	return otp
 }
 
-func awsAuthenticate(samlData string, conf masl.Config, role *masl.SAMLAssertionRole,
-	flags Flags) {
+// verifyPush waits for the push notification to be approved while showing a spinner
+func verifyPush(samlAssertionData masl.SAMLAssertionData, conf masl.Config, device masl.MFADevice,
+	apiToken string) (string, error) {
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		frames := `|/-\`
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				fmt.Fprint(out, "\r\033[K")
+				return
+			case <-time.After(100 * time.Millisecond):
+				fmt.Fprintf(out, "\r%c Approve the %s push notification on your device",
+					frames[i%len(frames)], device.DeviceType)
+			}
+		}
+	}()
+
+	samlData, err := masl.VerifyMFAPush(conf, device.DeviceID, samlAssertionData, apiToken)
+	close(done)
+	<-stopped
+	return samlData, err
+}
+
+func awsAuthenticate(assertionOutput *sts.AssumeRoleWithSAMLOutput, samlData string, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
 
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\n%s", err.Error())
-		os.Exit(1)
+		return err
 	}
 
-	assertionOutput := masl.AssumeRole(samlData, int64(conf.Duration), role)
-	masl.SetCredentials(assertionOutput, usr.HomeDir, flags.Profile, flags.LegacyToken)    //profile
-	masl.SetCredentials(assertionOutput, usr.HomeDir, role.AccountName, flags.LegacyToken) // account name
+	profiles := profileNames(conf, role, flags)
+	legacyToken := legacyToken(conf, role, flags)
+	for _, profile := range profiles {
+		if err := storeCredentials(assertionOutput, usr.HomeDir, conf, role, profile, legacyToken); err != nil {
+			return err
+		}
+	}
 
 	logger.Info("w00t w00t masl for you!, Successfully authenticated.")
 
@@ -130,22 +480,151 @@ func awsAuthenticate(samlData string, conf masl.Config, role *masl.SAMLAssertion
 	fmt.Printf("Assumed User: %v\n", *assertionOutput.AssumedRoleUser.Arn)
 	fmt.Printf("In account: %v [%v]\n", role.AccountID, role.AccountName)
 	fmt.Printf("Token will expire on: %v\n", *assertionOutput.Credentials.Expiration)
+	printSession(assertionOutput, samlData)
 	awsProfile := os.Getenv("AWS_PROFILE")
 	if awsProfile == "" {
 		awsProfile = "default"
 	}
-	if flags.Profile != awsProfile {
+	if !masl.Contains(profiles, awsProfile) {
 		fmt.Printf("\033[1;33m[WARNING] Your AWS credentials were stored under profile ")
-		fmt.Printf("'%s' but your AWS_PROFILE is set to '%s'!\n", flags.Profile, awsProfile)
+		fmt.Printf("'%s' but your AWS_PROFILE is set to '%s'!\n", strings.Join(profiles, "' & '"), awsProfile)
 		fmt.Print("Please read the FAQ in the README (https://github.com/glnds/masl) ")
 		fmt.Println("in order to fix this.\033[0m")
 	} else {
-		fmt.Printf("\033[1;32mUsing AWS Profile(s): '%v' & '%v'\033[0m\n", flags.Profile, role.AccountName)
+		fmt.Printf("\033[1;32mUsing AWS Profile(s): '%v'\033[0m\n", strings.Join(profiles, "' & '"))
+	}
+	return nil
+}
+
+// storeCredentials stores the credentials of a role in a profile. When enabled the profile's region,
+// output and credential_process are maintained in the AWS config file as well, with a
+// credential_process the credentials aren't stored as they would take precedence.
+func storeCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, homeDir string, conf masl.Config,
+	role *masl.SAMLAssertionRole, profile string, legacyToken bool) error {
+
+	if !conf.AWSConfig {
+		return masl.SetCredentials(assertionOutput, homeDir, profile, legacyToken)
+	}
+	// Only a configured region is written, a region set by hand is kept otherwise
+	settings := masl.ProfileSettings{Region: masl.ConfiguredRegion(conf, role), Output: conf.AWSOutput}
+	if conf.AWSCredentialProcess {
+		settings.CredentialProcess = credentialProcessSetting(role)
+	}
+	if err := masl.SetProfile(homeDir, profile, settings); err != nil {
+		return err
+	}
+	if conf.AWSCredentialProcess {
+		return masl.DeleteCredentials(homeDir, profile)
+	}
+	return masl.SetCredentials(assertionOutput, homeDir, profile, legacyToken)
+}
+
+// credentialProcessSetting returns the credential_process running masl for a role
+func credentialProcessSetting(role *masl.SAMLAssertionRole) string {
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "masl"
+	}
+	if strings.ContainsAny(executable, " \t") {
+		executable = strconv.Quote(executable)
+	}
+	return fmt.Sprintf("%s %s -account %s -role %s", executable, credentialProcessCommand, role.AccountID,
+		role.RoleName)
+}
+
+// printSession prints the session name, source identity and session tags of the role session
+func printSession(assertionOutput *sts.AssumeRoleWithSAMLOutput, samlData string) {
+	assumedRole := *assertionOutput.AssumedRoleUser.Arn
+	fmt.Printf("Session name: %v\n", assumedRole[strings.LastIndex(assumedRole, "/")+1:])
+	if assertionOutput.SourceIdentity != nil {
+		fmt.Printf("Source identity: %v\n", *assertionOutput.SourceIdentity)
+	}
+	// The session tags are only known from the SAML assertion, not for cached credentials
+	if samlData == "" {
+		return
+	}
+	attributes, err := masl.ParseSAMLAttributes(samlData)
+	if err != nil || len(attributes.PrincipalTags) == 0 {
+		return
+	}
+	tags := make([]string, 0, len(attributes.PrincipalTags))
+	for key, value := range attributes.PrincipalTags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	fmt.Printf("Session tags: %v\n", strings.Join(tags, ", "))
+}
+
+// profileNames returns the profiles to store the credentials of a role in. The profiles configured
+// for the role's account replace the default profile and the account name, an explicit -profile is
+// used as well.
+func profileNames(conf masl.Config, role *masl.SAMLAssertionRole, flags Flags) []string {
+	if profiles := accountProfiles(conf, role); len(profiles) > 0 {
+		if flags.ProfileSet {
+			return append([]string{flags.Profile}, profiles...)
+		}
+		return profiles
 	}
+	return []string{flags.Profile, role.AccountName}
 }
 
-func parseFlags(conf masl.Config) Flags {
+// accountProfiles returns the profiles configured for the role's account
+func accountProfiles(conf masl.Config, role *masl.SAMLAssertionRole) []string {
+	account, _ := masl.GetAccount(conf, role.AccountID)
+	return account.Profiles
+}
+
+// legacyToken reports whether the legacy aws_security_token is stored for a role, an explicit
+// -legacy-token flag overrides the account and global settings
+func legacyToken(conf masl.Config, role *masl.SAMLAssertionRole, flags Flags) bool {
+	if flags.LegacyTokenSet {
+		return flags.LegacyToken
+	}
+	return masl.LegacyToken(conf, role)
+}
+
+// credentialProcess prints the STS credentials as an AWS credential_process document on stdout
+func credentialProcess(assertionOutput *sts.AssumeRoleWithSAMLOutput, role *masl.SAMLAssertionRole) error {
+	document, err := masl.CredentialProcess(assertionOutput)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(document))
+	logger.Sugar().Infof("w00t w00t masl for you!, Credentials printed for account [%s].", role.AccountID)
+	return nil
+}
+
+// printEnv prints the STS credentials as statements to be evaluated by the user's shell
+func printEnv(assertionOutput *sts.AssumeRoleWithSAMLOutput, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
+
+	shell := masl.DetectShell()
+	if flags.Shell != "" {
+		var err error
+		if shell, err = masl.NormalizeShell(flags.Shell); err != nil {
+			return err
+		}
+	}
+	fmt.Print(masl.ShellExport(shell, masl.CredentialEnv(assertionOutput, masl.Region(conf, role))))
+	logger.Sugar().Infof("w00t w00t masl for you!, Credentials exported for account [%s].", role.AccountID)
+	return nil
+}
+
+// parseCommand splits an optional leading subcommand from the remaining arguments
+func parseCommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case credentialProcessCommand, execCommand, serveCommand, imdsCommand, consoleCommand, secretCommand,
+			logoutCommand:
+			return args[0], args[1:]
+		}
+	}
+	return "", args
+}
+
+func parseFlags(conf masl.Config, command string, args []string) Flags {
 	flags := new(Flags)
+	flags.Command = command
 
 	flag.BoolVar(&flags.Version, "version", false, "prints MASL version")
 	flag.BoolVar(&flags.LegacyToken, "legacy-token", conf.LegacyToken,
@@ -154,8 +633,27 @@ func parseFlags(conf masl.Config) Flags {
 	flag.StringVar(&flags.Env, "env", "", "Work environment")
 	flag.StringVar(&flags.Account, "account", "", "AWS Account ID or name")
 	flag.StringVar(&flags.Role, "role", "", "AWS role name")
-
-	flag.Parse()
+	flag.BoolVar(&flags.NoCache, "no-cache", false, "ignore cached credentials and OneLogin API tokens")
+	flag.BoolVar(&flags.Last, "last", false, "assume the previously selected role again")
+	flag.BoolVar(&flags.All, "all", false, "assume all matching roles, each in the profiles of its account")
+	flag.StringVar(&flags.Output, "output", "", "print the credentials instead of storing them (env)")
+	flag.StringVar(&flags.Shell, "shell", "", "shell for -output env (posix, fish, powershell or cmd)")
+	flag.StringVar(&flags.Address, "address", "", "local address to serve credentials on (serve, imds)")
+	flag.BoolVar(&flags.Open, "open", false, "open the sign-in URL in the browser (console)")
+	flag.StringVar(&flags.Destination, "destination", "", "console path or URL to open after signing in (console)")
+	flag.StringVar(&flags.Region, "region", "", "console region (console)")
+
+	// ExitOnError is set on the default FlagSet
+	_ = flag.CommandLine.Parse(args)
+	flags.Args = flag.CommandLine.Args()
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "profile":
+			flags.ProfileSet = true
+		case "legacy-token":
+			flags.LegacyTokenSet = true
+		}
+	})
 
 	if flags.Version {
 		if version == "" {
@@ -172,12 +670,7 @@ func initAccountFilter(conf masl.Config, flags Flags) []string {
 
 	var accountFilter []string
 	if flags.Account != "" {
-		account := masl.GetAccountID(conf, flags.Account)
-		if account != "" {
-			accountFilter = append(accountFilter, account)
-		} else {
-			accountFilter = append(accountFilter, flags.Account)
-		}
+		accountFilter = append(accountFilter, accountID(conf, flags))
 	} else if flags.Env != "" {
 		accountFilter = append(accountFilter, masl.GetAccountsForEnvironment(conf, flags.Env)...)
 	}
@@ -186,57 +679,79 @@ func initAccountFilter(conf masl.Config, flags Flags) []string {
 	return accountFilter
 }
 
-func selectRole(roles []*masl.SAMLAssertionRole) *masl.SAMLAssertionRole {
+// accountID resolves the -account flag, which is either an account ID or name
+func accountID(conf masl.Config, flags Flags) string {
+	if account := masl.GetAccountID(conf, flags.Account); account != "" {
+		return account
+	}
+	return flags.Account
+}
+
+func selectRole(roles []*masl.SAMLAssertionRole) (*masl.SAMLAssertionRole, error) {
 	if len(roles) == 1 {
-		return roles[0]
+		return roles[0], nil
+	}
+
+	if usr, err := user.Current(); err == nil {
+		masl.SortByHistory(usr.HomeDir, roles)
+	}
+	if terminal, ok := terminalOutput(); ok {
+		return pickRole(roles, terminal)
 	}
 
 	for index, role := range roles {
 		role.ID = index + 1
-		fmt.Printf("[%2d] > %s:%-15s :: %s\n", role.ID, role.AccountID, role.RoleArn[31:], role.AccountName)
+		fmt.Fprintf(out, "[%2d] > %s\n", role.ID, roleLabel(role))
 	}
 
 	// Choose a role
-	fmt.Print("Enter a role number:")
+	fmt.Fprint(out, "Enter a role number:")
 	reader := bufio.NewReader(os.Stdin)
 	roleNumber, _ := reader.ReadString('\n')
 	roleNumber = strings.TrimRight(roleNumber, "\r\n")
 	index, err := strconv.Atoi(roleNumber)
-	if err != nil {
-		fmt.Println(err)
-		logger.Fatal(err.Error())
+	if err != nil || index < 1 || index > len(roles) {
+		return nil, fmt.Errorf("invalid role number: %s", roleNumber)
 	}
-	return roles[index-1]
+	return roles[index-1], nil
 }
 
-func selectMFADevice(devices []masl.MFADevice, defaultMFADevice string) masl.MFADevice {
+// roleLabel describes a role in the role selection
+func roleLabel(role *masl.SAMLAssertionRole) string {
+	label := fmt.Sprintf("%s:%-15s :: %s", role.AccountID, role.RoleName, role.AccountName)
+	if role.Source != nil {
+		label += fmt.Sprintf(" (via %s:%s)", role.Source.AccountID, role.Source.RoleName)
+	}
+	return label
+}
+
+func selectMFADevice(devices []masl.MFADevice, defaultMFADevice string) (masl.MFADevice, error) {
 	if len(devices) == 1 {
-		return devices[0]
+		return devices[0], nil
 	}
 
 	if defaultMFADevice != "" {
 		// Try to select the default MFA device
 		for _, device := range devices {
 			if strings.EqualFold(device.DeviceType, defaultMFADevice) {
-				fmt.Printf("Picked your default defined MFA device.\n")
-				return device
+				fmt.Fprintf(out, "Picked your default defined MFA device.\n")
+				return device, nil
 			}
 		}
-		fmt.Printf("No MFA device match found for your default defined MFA Device: [%s].\n",
+		fmt.Fprintf(out, "No MFA device match found for your default defined MFA Device: [%s].\n",
 			defaultMFADevice)
 	}
 	// Manually select an MFA device
 	for index, device := range devices {
-		fmt.Printf("[%2d] > %s\n", index+1, device.DeviceType)
+		fmt.Fprintf(out, "[%2d] > %s\n", index+1, device.DeviceType)
 	}
-	fmt.Print("Enter the MFA device number:")
+	fmt.Fprint(out, "Enter the MFA device number:")
 	reader := bufio.NewReader(os.Stdin)
 	deviceNumber, _ := reader.ReadString('\n')
 	deviceNumber = strings.TrimRight(deviceNumber, "\r\n")
 	index, err := strconv.Atoi(deviceNumber)
-	if err != nil {
-		fmt.Println(err)
-		logger.Fatal(err.Error())
+	if err != nil || index < 1 || index > len(devices) {
+		return masl.MFADevice{}, fmt.Errorf("invalid MFA device number: %s", deviceNumber)
 	}
-	return devices[index-1]
+	return devices[index-1], nil
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/glnds/masl/internal/masl"
+	"golang.org/x/term"
+)
+
+// errSelectionCancelled the user left the role picker without selecting a role
+var errSelectionCancelled = errors.New("role selection cancelled")
+
+// The keys handled by the role picker
+const (
+	keyCtrlC     = 3
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyEscape    = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// picker holds the state of the interactive role picker
+type picker struct {
+	roles    []*masl.SAMLAssertionRole
+	query    string
+	matches  []*masl.SAMLAssertionRole
+	selected int
+	offset   int
+}
+
+func newPicker(roles []*masl.SAMLAssertionRole) *picker {
+	return &picker{roles: roles, matches: roles}
+}
+
+// terminalOutput returns the terminal the picker can draw on, if any
+func terminalOutput() (*os.File, bool) {
+	file, ok := out.(*os.File)
+	if !ok || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(file.Fd())) {
+		return nil, false
+	}
+	return file, true
+}
+
+// pickRole lets the user select a role in a full screen picker, filtering the roles while typing
+func pickRole(roles []*masl.SAMLAssertionRole, terminal *os.File) (*masl.SAMLAssertionRole, error) {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state) // nolint
+
+	// Switch to the alternate screen and hide the cursor while picking
+	fmt.Fprint(terminal, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(terminal, "\033[?25h\033[?1049l")
+
+	picker := newPicker(roles)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		_, height, err := term.GetSize(int(terminal.Fd()))
+		if err != nil {
+			height = 24
+		}
+		picker.render(terminal, height)
+
+		key, err := readKey(reader)
+		if err != nil {
+			return nil, err
+		}
+		role, done, err := picker.handleKey(key)
+		if done {
+			return role, err
+		}
+	}
+}
+
+// readKey reads a single key press, escape sequences are returned as a whole
+func readKey(reader *bufio.Reader) (string, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	if r != keyEscape || reader.Buffered() == 0 {
+		return string(r), nil
+	}
+	// CSI and SS3 sequences end with a letter or a tilde
+	sequence := []rune{r}
+	for reader.Buffered() > 0 {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		sequence = append(sequence, r)
+		if len(sequence) > 2 && (unicode.IsLetter(r) || r == '~') {
+			break
+		}
+	}
+	return string(sequence), nil
+}
+
+// handleKey updates the picker for a key press and reports when the picking is done
+func (picker *picker) handleKey(key string) (*masl.SAMLAssertionRole, bool, error) {
+	switch key {
+	case string(rune(keyEnter)), string(rune(keyNewline)):
+		if len(picker.matches) == 0 {
+			return nil, false, nil
+		}
+		return picker.matches[picker.selected], true, nil
+	case string(rune(keyCtrlC)), string(rune(keyEscape)):
+		return nil, true, errSelectionCancelled
+	case "\033[A", "\033OA", string(rune(keyCtrlP)):
+		picker.move(-1)
+	case "\033[B", "\033OB", string(rune(keyCtrlN)):
+		picker.move(1)
+	case "\033[5~":
+		picker.move(-10)
+	case "\033[6~":
+		picker.move(10)
+	case string(rune(keyBackspace)), string(rune(keyCtrlH)):
+		if picker.query != "" {
+			_, size := utf8.DecodeLastRuneInString(picker.query)
+			picker.filter(picker.query[:len(picker.query)-size])
+		}
+	case string(rune(keyCtrlU)):
+		picker.filter("")
+	default:
+		if r, _ := utf8.DecodeRuneInString(key); len(key) == utf8.RuneLen(r) && unicode.IsPrint(r) {
+			picker.filter(picker.query + key)
+		}
+	}
+	return nil, false, nil
+}
+
+// move moves the selection, staying within the matching roles
+func (picker *picker) move(delta int) {
+	picker.selected += delta
+	if picker.selected >= len(picker.matches) {
+		picker.selected = len(picker.matches) - 1
+	}
+	if picker.selected < 0 {
+		picker.selected = 0
+	}
+}
+
+// filter keeps the roles matching the query and resets the selection
+func (picker *picker) filter(query string) {
+	picker.query = query
+	picker.matches = filterRoles(picker.roles, query)
+	picker.selected = 0
+	picker.offset = 0
+}
+
+// filterRoles returns the roles matching every word of the query. A word matches when its
+// characters appear in order in the account name, account ID or role name.
+func filterRoles(roles []*masl.SAMLAssertionRole, query string) []*masl.SAMLAssertionRole {
+	words := strings.Fields(strings.ToLower(query))
+	var matches []*masl.SAMLAssertionRole
+	for _, role := range roles {
+		text := strings.ToLower(role.AccountName + " " + role.AccountID + " " + role.RoleName)
+		matched := true
+		for _, word := range words {
+			if !fuzzyMatch(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, role)
+		}
+	}
+	return matches
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in order in text
+func fuzzyMatch(text string, pattern string) bool {
+	for _, r := range pattern {
+		index := strings.IndexRune(text, r)
+		if index < 0 {
+			return false
+		}
+		text = text[index+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// render draws the picker, scrolling the list to keep the selected role visible
+func (picker *picker) render(writer io.Writer, height int) {
+	rows := height - 3
+	if rows < 1 {
+		rows = 1
+	}
+	if picker.selected < picker.offset {
+		picker.offset = picker.selected
+	}
+	if picker.selected >= picker.offset+rows {
+		picker.offset = picker.selected - rows + 1
+	}
+
+	// Raw mode requires explicit carriage returns
+	var screen strings.Builder
+	screen.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&screen, "Select a role (%d/%d) \033[2m[type to filter, arrows to move, enter to select]\033[0m\r\n",
+		len(picker.matches), len(picker.roles))
+	fmt.Fprintf(&screen, "> %s\r\n", picker.query)
+	for index := picker.offset; index < len(picker.matches) && index < picker.offset+rows; index++ {
+		role := picker.matches[index]
+		line := roleLabel(role)
+		switch {
+		case index == picker.selected:
+			fmt.Fprintf(&screen, "\033[7m> %s\033[0m\r\n", line)
+		case role.EnvironmentIndependent:
+			fmt.Fprintf(&screen, "  \033[1;36m%s\033[0m\r\n", line)
+		default:
+			fmt.Fprintf(&screen, "  %s\r\n", line)
+		}
+	}
+	fmt.Fprint(writer, screen.String())
+}
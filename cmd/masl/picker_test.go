@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/glnds/masl/internal/masl"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRoles() []*masl.SAMLAssertionRole {
+	return []*masl.SAMLAssertionRole{
+		{AccountID: "111111111111", AccountName: "governance-prod", RoleName: "admin"},
+		{AccountID: "222222222222", AccountName: "governance-dev", RoleName: "readonly"},
+		{AccountID: "333333333333", AccountName: "shared", RoleName: "admin", EnvironmentIndependent: true},
+	}
+}
+
+func TestFilterRoles(t *testing.T) {
+	roles := testRoles()
+	assert.Len(t, filterRoles(roles, ""), 3)
+	assert.Equal(t, roles[:2], filterRoles(roles, "gov"))
+	assert.Equal(t, roles[1:2], filterRoles(roles, "GovDev"))
+	assert.Equal(t, roles[2:], filterRoles(roles, "3333 adm"))
+	assert.Empty(t, filterRoles(roles, "xyz"))
+}
+
+func TestPickerKeys(t *testing.T) {
+	roles := testRoles()
+	picker := newPicker(roles)
+
+	picker.handleKey("\033[B")
+	picker.handleKey("\033[B")
+	picker.handleKey("\033[B")
+	role, done, err := picker.handleKey("\r")
+	assert.True(t, done)
+	assert.NoError(t, err)
+	assert.Equal(t, roles[2], role)
+
+	for _, key := range []string{"a", "d", "x"} {
+		picker.handleKey(key)
+	}
+	_, done, _ = picker.handleKey("\r")
+	assert.False(t, done, "nothing to select")
+	picker.handleKey("\x7f")
+	picker.handleKey("\033[A")
+	role, _, _ = picker.handleKey("\r")
+	assert.Equal(t, roles[0], role)
+
+	_, done, err = picker.handleKey("\x03")
+	assert.True(t, done)
+	assert.Equal(t, errSelectionCancelled, err)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"syscall"
+
+	"github.com/glnds/masl/internal/masl"
+	"golang.org/x/term"
+)
+
+const secretCommand = "secret"
+
+// secretNames the secrets which can be managed with masl secret
+var secretNames = []string{masl.SecretClientSecret, masl.SecretPassword, masl.SecretOTPSeed}
+
+var errSecretUsage = fmt.Errorf("usage: masl secret set|delete %s", strings.Join(secretNames, "|"))
+
+// store is the secret store, it's only opened when a secret is needed
+var store masl.SecretStore
+
+// secretStore opens the configured secret store
+func secretStore(conf masl.Config) func() (masl.SecretStore, error) {
+	return func() (masl.SecretStore, error) {
+		if store != nil {
+			return store, nil
+		}
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		store, err = masl.NewSecretStore(conf, usr.HomeDir, readPassphrase)
+		return store, err
+	}
+}
+
+// readPassphrase returns the passphrase of the encrypted secrets file from the
+// MASL_SECRET_PASSPHRASE environment variable or asks for it
+func readPassphrase() (string, error) {
+	if passphrase := os.Getenv("MASL_SECRET_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return "", errors.New("set MASL_SECRET_PASSPHRASE to unlock the masl secrets file")
+	}
+	fmt.Fprint(out, "masl secrets passphrase: ")
+	passphrase, err := term.ReadPassword(int(syscall.Stdin)) // nolint
+	fmt.Fprintln(out)
+	return string(passphrase), err
+}
+
+// manageSecret stores or deletes a secret in the secret store
+func manageSecret(conf masl.Config, args []string) error {
+	if len(args) != 2 || !masl.Contains(secretNames, args[1]) {
+		return errSecretUsage
+	}
+	secrets, err := secretStore(conf)()
+	if err != nil {
+		return err
+	}
+
+	name := args[1]
+	switch args[0] {
+	case "set":
+		value, err := readSecret(name)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("no value given for %s", name)
+		}
+		if err := secrets.Set(name, value); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Stored the %s.\n", name)
+	case "delete":
+		if err := secrets.Delete(name); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted the %s.\n", name)
+	default:
+		return errSecretUsage
+	}
+	return nil
+}
+
+// readSecret asks for the value of a secret, or reads it from stdin when it isn't a terminal
+func readSecret(name string) (string, error) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			return "", err
+		}
+		return strings.TrimRight(value, "\r\n"), nil
+	}
+	fmt.Fprintf(out, "Enter the %s: ", name)
+	value, err := term.ReadPassword(int(syscall.Stdin)) // nolint
+	fmt.Fprintln(out)
+	return string(value), err
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+)
+
+const (
+	serveCommand = "serve"
+	imdsCommand  = "imds"
+)
+
+// defaultServeAddress lets the OS pick a free loopback port
+const defaultServeAddress = "127.0.0.1:0"
+
+// serve runs a local server handing out the role credentials following the ECS container
+// credentials protocol, refreshing them for as long as masl is running
+func serve(assertionOutput *sts.AssumeRoleWithSAMLOutput, samlData string, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	address := flags.Address
+	if address == "" {
+		address = defaultServeAddress
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	source := masl.NewCredentialSource(conf, samlData, masl.SessionDuration(conf, role), role, assertionOutput,
+		relogin(conf, flags, role))
+	url := "http://" + listener.Addr().String() + masl.ECSCredentialsPath
+
+	printServing(role, []masl.EnvVar{
+		{Name: "AWS_CONTAINER_CREDENTIALS_FULL_URI", Value: url},
+		{Name: "AWS_CONTAINER_AUTHORIZATION_TOKEN", Value: token},
+	})
+	logger.Sugar().Infof("Serving credentials for role [%s] on [%s].", role.RoleArn, url)
+
+	return http.Serve(listener, masl.ECSHandler(source, token))
+}
+
+// serveIMDS runs a local emulation of the EC2 instance metadata service (IMDSv2) handing out the
+// role credentials, refreshing them for as long as masl is running
+func serveIMDS(assertionOutput *sts.AssumeRoleWithSAMLOutput, samlData string, conf masl.Config,
+	role *masl.SAMLAssertionRole, flags Flags) error {
+
+	address := flags.Address
+	if address == "" {
+		address = conf.IMDSAddress
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	source := masl.NewCredentialSource(conf, samlData, masl.SessionDuration(conf, role), role, assertionOutput,
+		relogin(conf, flags, role))
+	url := "http://" + listener.Addr().String()
+
+	printServing(role, []masl.EnvVar{{Name: "AWS_EC2_METADATA_SERVICE_ENDPOINT", Value: url}})
+	logger.Sugar().Infof("Serving instance metadata for role [%s] on [%s].", role.RoleArn, url)
+
+	return http.Serve(listener, masl.IMDSHandler(source, masl.Region(conf, role)))
+}
+
+// printServing tells the user which environment variables point their tools to masl
+func printServing(role *masl.SAMLAssertionRole, vars []masl.EnvVar) {
+	fmt.Fprintln(out, "\nw00t w00t masl for you!")
+	fmt.Fprintf(out, "Serving the credentials of %s in account: %v [%v]\n", role.RoleName, role.AccountID,
+		role.AccountName)
+	fmt.Fprintln(out, "Configure your tools with:")
+	fmt.Fprint(out, masl.ShellExport(masl.DetectShell(), vars))
+}
+
+// relogin returns a function logging in again to the role once the SAML assertion expired
+func relogin(conf masl.Config, flags Flags, role *masl.SAMLAssertionRole) func() (string, error) {
+	flags.Account = role.AccountID
+	flags.Role = role.RoleName
+	return func() (string, error) {
+		fmt.Fprintln(out, "\nThe credentials are about to expire, log in again to keep serving them.")
+		password, err := readPassword(conf)
+		if err != nil {
+			return "", err
+		}
+		samlData, _, err := login(conf, flags, password)
+		return samlData, err
+	}
+}
+
+// randomToken generates a token to authorize requests to the local server
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,104 @@
+package masl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// roleAttributeName is the SAML attribute containing the AWS roles
+const roleAttributeName = "https://aws.amazon.com/SAML/Attributes/Role"
+
+// MalformedRoleError lists the role attribute values which couldn't be parsed
+type MalformedRoleError struct {
+	Values []string
+	Errors []error
+}
+
+func (malformed *MalformedRoleError) Error() string {
+	messages := make([]string, len(malformed.Errors))
+	for index, err := range malformed.Errors {
+		messages[index] = err.Error()
+	}
+	return fmt.Sprintf("skipped %d malformed role(s): %s", len(malformed.Values),
+		strings.Join(messages, "; "))
+}
+
+// Unwrap makes a MalformedRoleError match ErrInvalidAssertion
+func (malformed *MalformedRoleError) Unwrap() error {
+	return ErrInvalidAssertion
+}
+
+func (malformed *MalformedRoleError) add(value string, err error) {
+	malformed.Values = append(malformed.Values, value)
+	malformed.Errors = append(malformed.Errors, err)
+}
+
+// parseRoleAttribute parses a role attribute value, a role ARN and a SAML provider ARN
+// separated by a comma in either order
+func parseRoleAttribute(value string) (SAMLAssertionRole, error) {
+	var role SAMLAssertionRole
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return role, fmt.Errorf("expected a role and principal ARN in [%s]", value)
+	}
+
+	var roleArn, principalArn *arn.ARN
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		parsed, err := arn.Parse(part)
+		if err != nil {
+			return role, fmt.Errorf("%s in [%s]", err, value)
+		}
+		switch {
+		case parsed.Service == "iam" && strings.HasPrefix(parsed.Resource, "role/"):
+			roleArn = &parsed
+		case parsed.Service == "iam" && strings.HasPrefix(parsed.Resource, "saml-provider/"):
+			principalArn = &parsed
+		default:
+			return role, fmt.Errorf("unexpected ARN [%s]", part)
+		}
+	}
+	if roleArn == nil || principalArn == nil {
+		return role, fmt.Errorf("expected a role and principal ARN in [%s]", value)
+	}
+
+	role, err := roleFromArn(*roleArn)
+	if err != nil {
+		return role, err
+	}
+	role.PrincipalArn = principalArn.String()
+	return role, nil
+}
+
+// parseRoleArn parses a role ARN
+func parseRoleArn(value string) (SAMLAssertionRole, error) {
+	parsed, err := arn.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return SAMLAssertionRole{}, fmt.Errorf("%s in [%s]", err, value)
+	}
+	if parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return SAMLAssertionRole{}, fmt.Errorf("unexpected ARN [%s]", value)
+	}
+	return roleFromArn(parsed)
+}
+
+// roleFromArn fills in the role details of a role ARN
+func roleFromArn(roleArn arn.ARN) (SAMLAssertionRole, error) {
+	// The resource is role/[path/]name
+	resource := strings.TrimPrefix(roleArn.Resource, "role")
+	separator := strings.LastIndex(resource, "/")
+	role := SAMLAssertionRole{
+		RoleArn:   roleArn.String(),
+		AccountID: roleArn.AccountID,
+		Partition: roleArn.Partition,
+		Path:      resource[:separator+1],
+		RoleName:  resource[separator+1:],
+	}
+	if role.RoleName == "" || role.AccountID == "" {
+		return role, fmt.Errorf("incomplete role ARN [%s]", role.RoleArn)
+	}
+	return role, nil
+}
@@ -0,0 +1,52 @@
+package masl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoleAttribute(t *testing.T) {
+	tests := []struct {
+		value     string
+		partition string
+		accountID string
+		path      string
+		roleName  string
+	}{
+		{"arn:aws:iam::123456789012:role/admin,arn:aws:iam::123456789012:saml-provider/onelogin",
+			"aws", "123456789012", "/", "admin"},
+		{"arn:aws:iam::123456789012:saml-provider/onelogin, arn:aws:iam::123456789012:role/admin",
+			"aws", "123456789012", "/", "admin"},
+		{"arn:aws:iam::123456789012:role/teams/ops/deployer,arn:aws:iam::123456789012:saml-provider/onelogin",
+			"aws", "123456789012", "/teams/ops/", "deployer"},
+		{"arn:aws-us-gov:iam::123456789012:role/admin,arn:aws-us-gov:iam::123456789012:saml-provider/onelogin",
+			"aws-us-gov", "123456789012", "/", "admin"},
+		{"arn:aws-cn:iam::123456789012:role/admin,arn:aws-cn:iam::123456789012:saml-provider/onelogin",
+			"aws-cn", "123456789012", "/", "admin"},
+	}
+	for _, test := range tests {
+		role, err := parseRoleAttribute(test.value)
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.partition, role.Partition, test.value)
+		assert.Equal(t, test.accountID, role.AccountID, test.value)
+		assert.Equal(t, test.path, role.Path, test.value)
+		assert.Equal(t, test.roleName, role.RoleName, test.value)
+		assert.Contains(t, role.PrincipalArn, ":saml-provider/onelogin", test.value)
+	}
+}
+
+func TestParseRoleAttributeMalformed(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"admin-role",
+		"arn:aws:iam::123456789012:role/admin",
+		"arn:aws:iam::123456789012:role/admin,arn:aws:iam::123456789012:role/other",
+		"arn:aws:iam::123456789012:role/admin,not-an-arn",
+		"arn:aws:iam::123456789012:user/admin,arn:aws:iam::123456789012:saml-provider/onelogin",
+		"arn:aws:iam::123456789012:role/,arn:aws:iam::123456789012:saml-provider/onelogin",
+	} {
+		_, err := parseRoleAttribute(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,60 @@
+package masl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The SAML attributes AWS uses to configure the role session
+const (
+	sessionDurationAttributeName = "https://aws.amazon.com/SAML/Attributes/SessionDuration"
+	sessionNameAttributeName     = "https://aws.amazon.com/SAML/Attributes/RoleSessionName"
+	sourceIdentityAttributeName  = "https://aws.amazon.com/SAML/Attributes/SourceIdentity"
+	principalTagAttributePrefix  = "https://aws.amazon.com/SAML/Attributes/PrincipalTag:"
+)
+
+// SAMLAttributes represents the session attributes in a SAML assertion
+type SAMLAttributes struct {
+	SessionDuration int64
+	RoleSessionName string
+	SourceIdentity  string
+	PrincipalTags   map[string]string
+}
+
+// ParseSAMLAttributes parses the attributes configuring the AWS role session from a SAML assertion
+func ParseSAMLAttributes(samlAssertion string) (SAMLAttributes, error) {
+	samlResponse, err := decodeSAMLResponse(samlAssertion)
+	if err != nil {
+		return SAMLAttributes{}, err
+	}
+	if samlResponse.Assertion == nil || samlResponse.Assertion.AttributeStatement == nil {
+		return SAMLAttributes{}, nil
+	}
+	return parseSAMLAttributes(samlResponse.Assertion.AttributeStatement.Attributes), nil
+}
+
+func parseSAMLAttributes(attributes []Attribute) SAMLAttributes {
+	samlAttributes := SAMLAttributes{PrincipalTags: map[string]string{}}
+	for _, attribute := range attributes {
+		if len(attribute.Values) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(attribute.Values[0].Value)
+		switch {
+		case attribute.Name == sessionDurationAttributeName:
+			duration, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				logger.Sugar().Warnf("Ignored invalid session duration [%s].", value)
+				continue
+			}
+			samlAttributes.SessionDuration = duration
+		case attribute.Name == sessionNameAttributeName:
+			samlAttributes.RoleSessionName = value
+		case attribute.Name == sourceIdentityAttributeName:
+			samlAttributes.SourceIdentity = value
+		case strings.HasPrefix(attribute.Name, principalTagAttributePrefix):
+			samlAttributes.PrincipalTags[strings.TrimPrefix(attribute.Name, principalTagAttributePrefix)] = value
+		}
+	}
+	return samlAttributes
+}
@@ -0,0 +1,46 @@
+package masl
+
+import (
+	b64 "encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSAMLAttributes(t *testing.T) {
+	assertion := b64.StdEncoding.EncodeToString([]byte(`<samlp:Response
+ xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+ <saml:Assertion><saml:AttributeStatement>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/Role">
+   <saml:AttributeValue>arn:aws:iam::123456789012:role/admin,arn:aws:iam::123456789012:saml-provider/onelogin</saml:AttributeValue>
+  </saml:Attribute>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/SessionDuration">
+   <saml:AttributeValue>28800</saml:AttributeValue>
+  </saml:Attribute>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/RoleSessionName">
+   <saml:AttributeValue>jane@example.com</saml:AttributeValue>
+  </saml:Attribute>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/SourceIdentity">
+   <saml:AttributeValue>jane</saml:AttributeValue>
+  </saml:Attribute>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/PrincipalTag:team">
+   <saml:AttributeValue>platform</saml:AttributeValue>
+  </saml:Attribute>
+ </saml:AttributeStatement></saml:Assertion>
+</samlp:Response>`))
+
+	attributes, err := ParseSAMLAttributes(assertion)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(28800), attributes.SessionDuration)
+	assert.Equal(t, "jane@example.com", attributes.RoleSessionName)
+	assert.Equal(t, "jane", attributes.SourceIdentity)
+	assert.Equal(t, map[string]string{"team": "platform"}, attributes.PrincipalTags)
+
+	// The session duration is the default when no duration is configured
+	roles, err := ParseSAMLAssertion(assertion, nil, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, roles, 1)
+	assert.Equal(t, int64(28800), SessionDuration(Config{}, roles[0]))
+	assert.Equal(t, int64(3600), SessionDuration(Config{Duration: 3600}, roles[0]))
+	assert.Equal(t, int64(defaultDuration), SessionDuration(Config{}, &SAMLAssertionRole{}))
+}
@@ -0,0 +1,206 @@
+package masl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// ProfileSettings represents the settings of a profile in the AWS config file
+type ProfileSettings struct {
+	Region            string
+	Output            string
+	CredentialProcess string
+}
+
+// awsConfigPath returns the location of the AWS config file
+func awsConfigPath(homeDir string) string {
+	if filename := os.Getenv("AWS_CONFIG_FILE"); filename != "" {
+		return filename
+	}
+	return homeDir + string(os.PathSeparator) + ".aws" + string(os.PathSeparator) + "config"
+}
+
+// awsConfigSection returns the section name of a profile, only the default profile isn't prefixed
+func awsConfigSection(profileName string) string {
+	if profileName == "default" {
+		return profileName
+	}
+	return "profile " + profileName
+}
+
+// SetProfile Apply the settings of a profile in the AWS config file. Empty settings are left as is.
+// Only the lines of the applied keys change: loading and saving the file as INI would flatten nested
+// values like the s3 settings and reformat every other profile.
+func SetProfile(homeDir string, profileName string, settings ProfileSettings) error {
+	filename := awsConfigPath(homeDir)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrAWSConfigFile, err)
+	}
+
+	content := setSectionKeys(string(data), awsConfigSection(profileName), [][2]string{
+		{"region", settings.Region},
+		{"output", settings.Output},
+		{"credential_process", settings.CredentialProcess},
+	})
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("%w: %s", ErrAWSConfigFile, err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		return fmt.Errorf("%w: %s", ErrAWSConfigFile, err)
+	}
+	logger.Sugar().Infof("AWS config saved to file for profile [%s].", profileName)
+	return nil
+}
+
+// setSectionKeys sets the keys with a value in a section of the INI content, the section is appended
+// when missing. Other lines are returned unchanged.
+func setSectionKeys(content string, section string, settings [][2]string) string {
+	lines := strings.SplitAfter(content, "\n")
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		name, ok := sectionName(line)
+		if !ok {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if name == section {
+			start = i
+		}
+	}
+
+	if start < 0 {
+		added := missingKeys(settings, map[string]bool{})
+		if len(added) == 0 {
+			return content
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if strings.TrimSpace(content) != "" {
+			content += "\n"
+		}
+		return content + "[" + section + "]\n" + strings.Join(added, "")
+	}
+
+	head, body, tail := lines[:start+1], lines[start+1:end], lines[end:]
+	done := map[string]bool{}
+	var updated []string
+	for i := 0; i < len(body); i++ {
+		key, ok := keyName(body[i])
+		value := sectionValue(settings, key)
+		if !ok || value == "" {
+			updated = append(updated, body[i])
+			continue
+		}
+		// Replace the key including its nested lines
+		ending := body[i][len(strings.TrimRight(body[i], "\r\n")):]
+		updated = append(updated, key+" = "+value+ending)
+		done[key] = true
+		for i+1 < len(body) && isNested(body[i+1]) {
+			i++
+		}
+	}
+
+	// Add the missing keys after the last non-blank line of the section
+	if added := missingKeys(settings, done); len(added) > 0 {
+		last := len(updated)
+		for last > 0 && strings.TrimSpace(updated[last-1]) == "" {
+			last--
+		}
+		if last > 0 && !strings.HasSuffix(updated[last-1], "\n") {
+			updated[last-1] += "\n"
+		} else if last == 0 && !strings.HasSuffix(head[start], "\n") {
+			head[start] += "\n"
+		}
+		updated = append(updated[:last:last], append(added, updated[last:]...)...)
+	}
+	return strings.Join(head, "") + strings.Join(updated, "") + strings.Join(tail, "")
+}
+
+// missingKeys returns the key lines of the settings with a value that aren't done yet
+func missingKeys(settings [][2]string, done map[string]bool) []string {
+	var added []string
+	for _, setting := range settings {
+		if setting[1] != "" && !done[setting[0]] {
+			added = append(added, setting[0]+" = "+setting[1]+"\n")
+		}
+	}
+	return added
+}
+
+// sectionName returns the name of a section header line
+func sectionName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	closing := strings.Index(trimmed, "]")
+	if closing < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1:closing]), true
+}
+
+// keyName returns the key of a top-level key line, nested values are indented
+func keyName(line string) (string, bool) {
+	if isNested(line) {
+		return "", false
+	}
+	separator := strings.Index(line, "=")
+	if separator < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(line[:separator]), true
+}
+
+// isNested reports whether a line is an indented, nested value of the key above
+func isNested(line string) bool {
+	return strings.TrimSpace(line) != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// sectionValue returns the value to set for a key
+func sectionValue(settings [][2]string, key string) string {
+	for _, setting := range settings {
+		if setting[0] == key {
+			return setting[1]
+		}
+	}
+	return ""
+}
+
+// DeleteCredentials removes the credentials of a profile from the AWS credentials file, they would
+// take precedence over the credential_process of the profile
+func DeleteCredentials(homeDir string, profileName string) error {
+	ini.PrettyFormat = false
+
+	filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if filename == "" {
+		filename = homeDir + string(os.PathSeparator) + ".aws" + string(os.PathSeparator) + "credentials"
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	cfg, err := ini.Load(filename)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
+	}
+	if _, err := cfg.GetSection(profileName); err != nil {
+		return nil
+	}
+	cfg.DeleteSection(profileName)
+	if err := cfg.SaveTo(filename); err != nil {
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
+	}
+	logger.Sugar().Infof("AWS credentials removed from file for profile [%s].", profileName)
+	return nil
+}
@@ -0,0 +1,117 @@
+package masl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProfile(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	os.Unsetenv("AWS_CONFIG_FILE")
+	os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	assert.NoError(t, SetProfile(homeDir, "default", ProfileSettings{Region: "eu-west-1"}))
+	filename := filepath.Join(homeDir, ".aws", "config")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`# managed by hand
+[profile other]
+region = us-east-1
+
+[profile prod]
+# keep this comment
+region = us-west-2
+cli_pager =
+`), 0644))
+
+	assert.NoError(t, SetProfile(homeDir, "prod", ProfileSettings{Region: "eu-west-1", Output: "json",
+		CredentialProcess: "masl credential-process -account 123456789012 -role admin"}))
+	assert.NoError(t, SetProfile(homeDir, "default", ProfileSettings{Region: "eu-central-1"}))
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, `# managed by hand
+[profile other]
+region = us-east-1
+
+[profile prod]
+# keep this comment
+region = eu-west-1
+cli_pager =
+output = json
+credential_process = masl credential-process -account 123456789012 -role admin
+
+[default]
+region = eu-central-1
+`, string(data))
+}
+
+func TestSetProfileNested(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	filename := filepath.Join(homeDir, "config")
+	os.Setenv("AWS_CONFIG_FILE", filename)
+	defer os.Unsetenv("AWS_CONFIG_FILE")
+
+	before := `[default]
+region=us-east-1
+s3 =
+  max_concurrent_requests = 20
+  multipart_threshold = 64MB
+
+[profile prod]
+output = text
+region = us-west-2
+s3 =
+    addressing_style = path
+`
+	after := `
+[profile other]
+role_arn=arn:aws:iam::123456789012:role/admin
+source_profile = prod
+`
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(before+after), 0644))
+
+	assert.NoError(t, SetProfile(homeDir, "prod", ProfileSettings{Region: "eu-west-1", Output: "json",
+		CredentialProcess: "masl credential-process -account 123456789012 -role admin"}))
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, `[default]
+region=us-east-1
+s3 =
+  max_concurrent_requests = 20
+  multipart_threshold = 64MB
+
+[profile prod]
+output = json
+region = eu-west-1
+s3 =
+    addressing_style = path
+credential_process = masl credential-process -account 123456789012 -role admin
+`+after, string(data))
+}
+
+func TestDeleteCredentials(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	assert.NoError(t, DeleteCredentials(homeDir, "prod"))
+	output := testOutput("AKID", time.Now().Add(time.Hour))
+	assert.NoError(t, SetCredentials(output, homeDir, "prod", false))
+	assert.NoError(t, SetCredentials(output, homeDir, "other", false))
+	assert.NoError(t, DeleteCredentials(homeDir, "prod"))
+
+	data, err := ioutil.ReadFile(filepath.Join(homeDir, ".aws", "credentials"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "[prod]")
+	assert.Contains(t, string(data), "[other]")
+}
@@ -0,0 +1,95 @@
+package masl
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const cacheFileName = "cache.json"
+
+// CacheEntry represents the STS credentials cached for an account and role
+type CacheEntry struct {
+	Role   SAMLAssertionRole             `json:"role"`
+	Output *sts.AssumeRoleWithSAMLOutput `json:"output"`
+}
+
+// credentialCache holds the cached credentials keyed by account ID and role ARN
+type credentialCache map[string]CacheEntry
+
+func cacheKey(accountID string, roleArn string) string {
+	return accountID + "|" + roleArn
+}
+
+// validFor reports whether the cached credentials are valid for at least the given lifetime
+func (entry CacheEntry) validFor(minLifetime time.Duration) bool {
+	if entry.Output == nil || entry.Output.Credentials == nil ||
+		entry.Output.Credentials.Expiration == nil {
+		return false
+	}
+	return time.Until(*entry.Output.Credentials.Expiration) > minLifetime
+}
+
+func readCache(homeDir string) credentialCache {
+	cache := credentialCache{}
+	if !readJSONFile(homeDir, cacheFileName, &cache) || cache == nil {
+		return credentialCache{}
+	}
+	return cache
+}
+
+// CachedCredentials search the credential cache for the given account and role name. Credentials
+// are only returned when exactly one role matches and it is valid for at least minLifetime. Without
+// a role name nothing is returned: the SAML assertion may offer roles that aren't cached.
+func CachedCredentials(homeDir string, accountID string, role string,
+	minLifetime time.Duration) (*SAMLAssertionRole, *sts.AssumeRoleWithSAMLOutput) {
+
+	if role == "" {
+		return nil, nil
+	}
+	var match *CacheEntry
+	for _, entry := range readCache(homeDir) {
+		entry := entry
+		if entry.Role.AccountID != accountID || !entry.validFor(minLifetime) {
+			continue
+		}
+		if !strings.EqualFold(role, entry.Role.RoleName) {
+			continue
+		}
+		if match != nil {
+			logger.Info("Multiple cached roles match, the cache is ignored")
+			return nil, nil
+		}
+		match = &entry
+	}
+	if match == nil {
+		return nil, nil
+	}
+	logger.Sugar().Infof("Using cached credentials for role [%s].", match.Role.RoleArn)
+	return &match.Role, match.Output
+}
+
+// CacheCredentials store the STS credentials of a role in the credential cache
+func CacheCredentials(homeDir string, role *SAMLAssertionRole,
+	assertionOutput *sts.AssumeRoleWithSAMLOutput) error {
+
+	cache := readCache(homeDir)
+	for key, entry := range cache {
+		if !entry.validFor(0) {
+			delete(cache, key)
+		}
+	}
+	cache[cacheKey(role.AccountID, role.RoleArn)] = CacheEntry{Role: *role, Output: assertionOutput}
+
+	return writeJSONFile(homeDir, cacheFileName, cache)
+}
+
+// ClearCache removes all cached credentials
+func ClearCache(homeDir string) error {
+	if err := os.Remove(maslPath(homeDir, cacheFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
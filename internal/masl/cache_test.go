@@ -0,0 +1,57 @@
+package masl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedCredentials(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".masl"), 0700))
+
+	admin := &SAMLAssertionRole{AccountID: "111111111111", RoleArn: "arn:aws:iam::111111111111:role/admin",
+		RoleName: "admin"}
+	reader := &SAMLAssertionRole{AccountID: "111111111111", RoleArn: "arn:aws:iam::111111111111:role/reader",
+		RoleName: "reader"}
+	expired := &SAMLAssertionRole{AccountID: "222222222222", RoleArn: "arn:aws:iam::222222222222:role/admin",
+		RoleName: "admin"}
+
+	assert.NoError(t, CacheCredentials(homeDir, expired, testOutput("EXPIRED", time.Now().Add(-time.Minute))))
+	assert.NoError(t, CacheCredentials(homeDir, admin, testOutput("ADMIN", time.Now().Add(time.Hour))))
+
+	info, err := os.Stat(maslPath(homeDir, cacheFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	// Expired credentials are removed on the next save
+	assert.Len(t, readCache(homeDir), 1)
+
+	role, output := CachedCredentials(homeDir, "111111111111", "admin", 15*time.Minute)
+	assert.Equal(t, "admin", role.RoleName)
+	assert.Equal(t, "ADMIN", *output.Credentials.AccessKeyId)
+
+	// Without a role name the role is picked from the SAML assertion, even with a single cached role
+	role, output = CachedCredentials(homeDir, "111111111111", "", 15*time.Minute)
+	assert.Nil(t, role)
+	assert.Nil(t, output)
+
+	// Credentials expiring within the minimum lifetime aren't reused
+	role, output = CachedCredentials(homeDir, "111111111111", "admin", 2*time.Hour)
+	assert.Nil(t, role)
+	assert.Nil(t, output)
+
+	assert.NoError(t, CacheCredentials(homeDir, reader, testOutput("READER", time.Now().Add(time.Hour))))
+	role, output = CachedCredentials(homeDir, "111111111111", "Reader", 15*time.Minute)
+	assert.Equal(t, "reader", role.RoleName)
+	assert.Equal(t, "READER", *output.Credentials.AccessKeyId)
+
+	assert.NoError(t, ClearCache(homeDir))
+	assert.Empty(t, readCache(homeDir))
+	assert.NoError(t, ClearCache(homeDir))
+}
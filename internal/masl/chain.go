@@ -0,0 +1,126 @@
+package masl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// maxChainedDuration is the longest session AWS allows for a chained role
+const maxChainedDuration = 3600
+
+// defaultSessionName is used for chained roles when the SAML session name is unknown
+const defaultSessionName = "masl"
+
+// chainRoles returns the roles which can be reached from the SAML roles through the chains of the
+// configured accounts. A chain can start from the target of another chain.
+func chainRoles(samlRoles []*SAMLAssertionRole, accountInfo Accounts) []*SAMLAssertionRole {
+	type pendingChain struct {
+		accountID string
+		chain     Chain
+	}
+	var pending []pendingChain
+	for _, account := range accountInfo {
+		for _, chain := range account.Chains {
+			pending = append(pending, pendingChain{account.ID, chain})
+		}
+	}
+
+	roles := append([]*SAMLAssertionRole{}, samlRoles...)
+	var chained []*SAMLAssertionRole
+	// Resolve the chains whose source is known until none are left or none can be resolved
+	for progress := true; progress; {
+		progress = false
+		remaining := pending[:0]
+		for _, entry := range pending {
+			source := chainSource(roles, entry.accountID, entry.chain.SourceRole)
+			if source == nil {
+				remaining = append(remaining, entry)
+				continue
+			}
+			progress = true
+
+			target, err := parseRoleArn(entry.chain.TargetRoleArn)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("skipped chained role: %s", err))
+				continue
+			}
+			target.PrincipalArn = source.PrincipalArn
+			target.AccountName, target.EnvironmentIndependent = SearchAccounts(accountInfo, target.AccountID)
+			target.SessionDuration = source.SessionDuration
+			target.Source = source
+			if source.Source != nil {
+				target.Source = source.Source
+			}
+			target.Chain = append(append([]Chain{}, source.Chain...), entry.chain)
+
+			roles = append(roles, &target)
+			chained = append(chained, &target)
+		}
+		pending = remaining
+	}
+	for _, entry := range pending {
+		logger.Warn(fmt.Sprintf("skipped chained role [%s]: source role [%s] not available",
+			entry.chain.TargetRoleArn, entry.chain.SourceRole))
+	}
+	return chained
+}
+
+// chainSource finds the source role of a chain by ARN, or by name within the chain's account
+func chainSource(roles []*SAMLAssertionRole, accountID string, sourceRole string) *SAMLAssertionRole {
+	for _, role := range roles {
+		if role.RoleArn == sourceRole ||
+			(role.AccountID == accountID && strings.EqualFold(role.RoleName, sourceRole)) {
+			return role
+		}
+	}
+	return nil
+}
+
+// assumeChain assumes the chained roles in turn, starting from the SAML role's credentials
+func (client *Client) assumeChain(conf Config, samlRole *SAMLAssertionRole, chain []Chain, duration int64,
+	output *sts.AssumeRoleWithSAMLOutput) (*sts.AssumeRoleWithSAMLOutput, error) {
+
+	if duration > maxChainedDuration {
+		duration = maxChainedDuration
+	}
+	sessionName := defaultSessionName
+	if output.AssumedRoleUser != nil && output.AssumedRoleUser.Arn != nil {
+		// The assumed role ARN ends with the session name of the SAML user
+		assumedRole := *output.AssumedRoleUser.Arn
+		sessionName = assumedRole[strings.LastIndex(assumedRole, "/")+1:]
+	}
+
+	chainedOutput := *output
+	for _, link := range chain {
+		stsClient, err := client.stsClient(conf, samlRole, chainedOutput.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		input := sts.AssumeRoleInput{
+			DurationSeconds: aws.Int64(duration),
+			RoleArn:         aws.String(link.TargetRoleArn),
+			RoleSessionName: aws.String(sessionName),
+		}
+		if link.SessionName != "" {
+			input.RoleSessionName = aws.String(link.SessionName)
+		}
+		if link.ExternalID != "" {
+			input.ExternalId = aws.String(link.ExternalID)
+		}
+
+		assumeOutput, err := stsClient.AssumeRole(&input)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrSTSDenied, link.TargetRoleArn, err)
+		}
+		chainedOutput.Credentials = assumeOutput.Credentials
+		chainedOutput.AssumedRoleUser = assumeOutput.AssumedRoleUser
+		chainedOutput.PackedPolicySize = assumeOutput.PackedPolicySize
+		if assumeOutput.SourceIdentity != nil {
+			chainedOutput.SourceIdentity = assumeOutput.SourceIdentity
+		}
+	}
+	return &chainedOutput, nil
+}
@@ -0,0 +1,77 @@
+package masl
+
+import (
+	b64 "encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRoleAssertion(roles ...string) string {
+	values := ""
+	for _, role := range roles {
+		values += `<saml:AttributeValue>` + role + `</saml:AttributeValue>`
+	}
+	return b64.StdEncoding.EncodeToString([]byte(`<samlp:Response
+ xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+ <saml:Assertion><saml:AttributeStatement>
+  <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/Role">` + values + `</saml:Attribute>
+ </saml:AttributeStatement></saml:Assertion>
+</samlp:Response>`))
+}
+
+func TestChainedRoles(t *testing.T) {
+	accounts := Accounts{
+		{ID: "111111111111", Name: "hub", Chains: []Chain{
+			{SourceRole: "arn:aws:iam::222222222222:role/deployer",
+				TargetRoleArn: "arn:aws:iam::333333333333:role/ops/reader"},
+			{SourceRole: "hub", TargetRoleArn: "arn:aws:iam::222222222222:role/deployer", ExternalID: "external"},
+			{SourceRole: "unknown", TargetRoleArn: "arn:aws:iam::444444444444:role/admin"},
+		}},
+		{ID: "333333333333", Name: "workload"},
+	}
+	assertion := testRoleAssertion(
+		"arn:aws:iam::111111111111:role/hub,arn:aws:iam::111111111111:saml-provider/onelogin")
+
+	roles, err := ParseSAMLAssertion(assertion, accounts, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, roles, 3)
+
+	roles, err = ParseSAMLAssertion(assertion, accounts, []string{"333333333333"}, "reader")
+	assert.NoError(t, err)
+	assert.Len(t, roles, 1)
+	reader := roles[0]
+	assert.Equal(t, "workload", reader.AccountName)
+	assert.Equal(t, "/ops/", reader.Path)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/hub", reader.Source.RoleArn)
+	assert.Equal(t, reader.Source.PrincipalArn, reader.PrincipalArn)
+	assert.Len(t, reader.Chain, 2)
+
+	stsClient := &fakeSTS{}
+	var configs []*aws.Config
+	client := NewClient()
+	client.NewSTS = func(cfg *aws.Config) STSAPI {
+		configs = append(configs, cfg)
+		return stsClient
+	}
+	output, err := client.AssumeRole(Config{Region: "eu-west-1"}, assertion, 7200, reader)
+	assert.NoError(t, err)
+	assert.Equal(t, reader.RoleArn, *output.Credentials.AccessKeyId)
+	assert.Equal(t, 1, stsClient.calls)
+	assert.Len(t, stsClient.chains, 2)
+	assert.Equal(t, "external", *stsClient.chains[0].ExternalId)
+	assert.Equal(t, "me@example.com", *stsClient.chains[0].RoleSessionName)
+	assert.Equal(t, int64(maxChainedDuration), *stsClient.chains[1].DurationSeconds)
+
+	// Every hop is signed with the credentials of the previous role
+	assert.Len(t, configs, 3)
+	assert.Nil(t, configs[0].Credentials)
+	creds, err := configs[1].Credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "REFRESHED", creds.AccessKeyID)
+	creds, err = configs[2].Credentials.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, reader.Chain[0].TargetRoleArn, creds.AccessKeyID)
+	assert.Equal(t, "eu-west-1", *configs[2].Region)
+}
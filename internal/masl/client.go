@@ -0,0 +1,124 @@
+package masl
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// STSAPI represents the AWS STS operations used by masl
+type STSAPI interface {
+	AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error)
+	AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+}
+
+// Clock represents the source of time used by masl
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SystemClock is the Clock backed by the system time
+var SystemClock Clock = systemClock{}
+
+// STSFactory creates the AWS STS client for a role. The config holds the region, the STS endpoint
+// and, for chained roles, the credentials signing the requests.
+type STSFactory func(cfg *aws.Config) STSAPI
+
+// Client holds the dependencies used to talk to OneLogin and AWS
+type Client struct {
+	HTTPClient *http.Client
+	// NewSTS replaces the AWS STS clients created from the default AWS session when set
+	NewSTS STSFactory
+	Clock  Clock
+
+	mutex sync.Mutex
+	// apiVersions holds the detected OneLogin API version by API URL
+	apiVersions map[string]string
+}
+
+// NewClient creates a Client with the default dependencies
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Clock:      SystemClock,
+	}
+}
+
+// DefaultClient is the Client used by the package level functions
+var DefaultClient = NewClient()
+
+// stsClient returns the STS client for a role, signing the requests with the given credentials
+func (client *Client) stsClient(conf Config, role *SAMLAssertionRole, creds *sts.Credentials) (STSAPI, error) {
+	cfg := stsConfig(conf, role)
+	if creds != nil {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(*creds.AccessKeyId,
+			*creds.SecretAccessKey, *creds.SessionToken))
+	}
+	if client.NewSTS != nil {
+		return client.NewSTS(cfg), nil
+	}
+	session, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sts.New(session), nil
+}
+
+// GenerateToken generates a OneLogin API token using the DefaultClient
+func GenerateToken(conf Config) (string, error) {
+	return DefaultClient.GenerateToken(conf)
+}
+
+// CachedToken returns a cached or new OneLogin API token using the DefaultClient
+func CachedToken(conf Config, homeDir string) (string, error) {
+	return DefaultClient.CachedToken(conf, homeDir)
+}
+
+// CachedSAMLAssertion requests a SAML assertion with the cached OneLogin API token using the DefaultClient
+func CachedSAMLAssertion(conf Config, homeDir string, password string) (SAMLAssertionData, string, error) {
+	return DefaultClient.CachedSAMLAssertion(conf, homeDir, password)
+}
+
+// RevokeToken revokes the cached OneLogin API token using the DefaultClient
+func RevokeToken(conf Config, homeDir string) error {
+	return DefaultClient.RevokeToken(conf, homeDir)
+}
+
+// SAMLAssertion requests a SAML assertion using the DefaultClient
+func SAMLAssertion(conf Config, password string, apiToken string) (SAMLAssertionData, error) {
+	return DefaultClient.SAMLAssertion(conf, password, apiToken)
+}
+
+// VerifyMFA verifies an MFA one-time password using the DefaultClient
+func VerifyMFA(conf Config, deviceID int, stateToken string, otp string,
+	apiToken string) (string, error) {
+	return DefaultClient.VerifyMFA(conf, deviceID, stateToken, otp, apiToken)
+}
+
+// VerifyMFATOTP verifies the MFA device with a generated one-time password using the DefaultClient
+func VerifyMFATOTP(conf Config, deviceID int, stateToken string, seed string, apiToken string) (string, error) {
+	return DefaultClient.VerifyMFATOTP(conf, deviceID, stateToken, seed, apiToken)
+}
+
+// VerifyMFAPush verifies an MFA push notification using the DefaultClient
+func VerifyMFAPush(conf Config, deviceID int, samlAssertionData SAMLAssertionData,
+	apiToken string) (string, error) {
+	return DefaultClient.VerifyMFAPush(conf, deviceID, samlAssertionData, apiToken)
+}
+
+// AssumeRole assumes a role on AWS using the DefaultClient
+func AssumeRole(conf Config, samlAssertion string, duration int64,
+	role *SAMLAssertionRole) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return DefaultClient.AssumeRole(conf, samlAssertion, duration, role)
+}
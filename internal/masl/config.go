@@ -1,6 +1,7 @@
 package masl
 
 import (
+	"fmt"
 	"os"
 	"os/user"
 	"strings"
@@ -8,28 +9,69 @@ import (
 	"github.com/BurntSushi/toml"
 )
 
-// Accounts represents the accounts section of the masl config file
-type Accounts []struct {
-	ID                     string `toml:"ID"`
-	Name                   string `toml:"Name"`
-	EnvironmentIndependent bool   `toml:"EnvironmentIndependent"`
+// Account represents an account entry in the masl config file
+type Account struct {
+	ID                     string            `toml:"ID"`
+	Name                   string            `toml:"Name"`
+	EnvironmentIndependent bool              `toml:"EnvironmentIndependent"`
+	Region                 string            `toml:"Region"`
+	Duration               int               `toml:"Duration"`
+	Profiles               []string          `toml:"Profiles"`
+	LegacyToken            *bool             `toml:"LegacyToken"`
+	STSEndpoint            string            `toml:"STSEndpoint"`
+	DefaultRole            string            `toml:"DefaultRole"`
+	RoleAliases            map[string]string `toml:"RoleAliases"`
+	Chains                 []Chain           `toml:"Chains"`
+}
+
+// Chain represents a role assumed with sts:AssumeRole from a role of the account. The source role
+// is either the name or ARN of a role in the SAML assertion or the target role ARN of another chain.
+type Chain struct {
+	SourceRole    string `toml:"SourceRole"`
+	TargetRoleArn string `toml:"TargetRoleArn"`
+	ExternalID    string `toml:"ExternalID"`
+	SessionName   string `toml:"SessionName"`
 }
 
+// Accounts represents the accounts section of the masl config file
+type Accounts []Account
+
 // Config represents the masl config file
 type Config struct {
-	BaseURL         string `toml:"BaseURL"`
-	ClientID        string `toml:"ClientID"`
-	ClientSecret    string `toml:"ClientSecret"`
-	AppID           string `toml:"AppID"`
-	Subdomain       string `toml:"Subdomain"`
-	Username        string `toml:"Username"`
-	Duration        int    `toml:"Duration"`
-	Profile         string `toml:"Profile"`
-	DefaultRole     string `toml:"DefaultRole"`
-	LegacyToken     bool   `toml:"LegacyToken"`
-	Debug           bool   `toml:"Debug"`
-	DefaulMFADevice string `toml:"DefaulMFADevice"`
-	Environments    []struct {
+	BaseURL              string `toml:"BaseURL"`
+	APIVersion           string `toml:"APIVersion"`
+	ClientID             string `toml:"ClientID"`
+	ClientSecret         string `toml:"ClientSecret"`
+	ClientSecretCommand  string `toml:"ClientSecretCommand"`
+	PasswordCommand      string `toml:"PasswordCommand"`
+	SecretStore          string `toml:"SecretStore"`
+	OTPSecretCommand     string `toml:"OTPSecretCommand"`
+	OTPDigits            int    `toml:"OTPDigits"`
+	OTPPeriod            int    `toml:"OTPPeriod"`
+	OTPAlgorithm         string `toml:"OTPAlgorithm"`
+	AppID                string `toml:"AppID"`
+	Subdomain            string `toml:"Subdomain"`
+	Username             string `toml:"Username"`
+	Duration             int    `toml:"Duration"`
+	Profile              string `toml:"Profile"`
+	DefaultRole          string `toml:"DefaultRole"`
+	LegacyToken          bool   `toml:"LegacyToken"`
+	Debug                bool   `toml:"Debug"`
+	DefaulMFADevice      string `toml:"DefaulMFADevice"`
+	Region               string `toml:"Region"`
+	STSEndpoint          string `toml:"STSEndpoint"`
+	IMDSAddress          string `toml:"IMDSAddress"`
+	FederationURL        string `toml:"FederationURL"`
+	AWSConfig            bool   `toml:"AWSConfig"`
+	AWSOutput            string `toml:"AWSOutput"`
+	AWSCredentialProcess bool   `toml:"AWSCredentialProcess"`
+	DisableCache         bool   `toml:"DisableCache"`
+	CacheMinLifetime     int    `toml:"CacheMinLifetime"`
+	PushPollInterval     int    `toml:"PushPollInterval"`
+	PushTimeout          int    `toml:"PushTimeout"`
+	PreferPush           bool   `toml:"PreferPush"`
+	LoginAttempts        int    `toml:"LoginAttempts"`
+	Environments         []struct {
 		Name     string   `toml:"Name"`
 		Accounts []string `toml:"Accounts"`
 	} `toml:"Environments"`
@@ -40,20 +82,28 @@ type Config struct {
 var logger = GetLogger("info")
 
 // GetConfig reads the .masl/config.toml configuration file for initialization.
-func GetConfig() Config {
+func GetConfig() (Config, error) {
+
+	// Set default values
+	conf := Config{Profile: "masl", LegacyToken: false, Debug: false,
+		CacheMinLifetime: 900, PushPollInterval: 2, PushTimeout: 60, LoginAttempts: defaultLoginAttempts,
+		IMDSAddress: "127.0.0.1:1338"}
 
 	usr, err := user.Current()
 	if err != nil {
-		logger.Fatal(err.Error())
+		return conf, err
 	}
 
 	// Read .masl/config.toml config file for initialization
-	conf := Config{Profile: "masl", LegacyToken: false, Debug: false, Duration: 3600} // Set default values
-	if _, err := toml.DecodeFile(usr.HomeDir+string(os.PathSeparator)+".masl"+string(os.PathSeparator)+"config.toml", &conf); err != nil {
-		logger.Fatal(err.Error())
+	filename := usr.HomeDir + string(os.PathSeparator) + ".masl" + string(os.PathSeparator) + "config.toml"
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return conf, fmt.Errorf("%w: %s", ErrConfigMissing, filename)
+	}
+	if _, err := toml.DecodeFile(filename, &conf); err != nil {
+		return conf, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
 	}
 
-	return conf
+	return conf, nil
 }
 
 // SearchAccounts search an account name for a given acount id
@@ -67,6 +117,16 @@ func SearchAccounts(accountInfo Accounts, accountID string) (string, bool) {
 	return "untitled", false
 }
 
+// GetAccount get the account entry for a given account id
+func GetAccount(conf Config, accountID string) (Account, bool) {
+	for _, account := range conf.Accounts {
+		if account.ID == accountID {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
 // GetAccountID get the account id for a given acount name (alias)
 func GetAccountID(conf Config, name string) string {
 	var id string
@@ -78,6 +138,78 @@ func GetAccountID(conf Config, name string) string {
 	return id
 }
 
+// roleAlias returns the role name for an alias of the account, or the role itself
+func (account Account) roleAlias(role string) string {
+	for alias, name := range account.RoleAliases {
+		if strings.EqualFold(alias, role) {
+			return name
+		}
+	}
+	return role
+}
+
+// roleAlias returns the role name for an alias of the given account, or the role itself
+func (accounts Accounts) roleAlias(accountID string, role string) string {
+	for _, account := range accounts {
+		if account.ID == accountID {
+			return account.roleAlias(role)
+		}
+	}
+	return role
+}
+
+// ResolveRole resolves the role name for an account. An alias is replaced with its role name and
+// without a role the default role of the account, or the global default role, is returned.
+func ResolveRole(conf Config, accountID string, role string) string {
+	account, _ := GetAccount(conf, accountID)
+	if role == "" {
+		role = account.DefaultRole
+	}
+	if role == "" {
+		role = conf.DefaultRole
+	}
+	return account.roleAlias(role)
+}
+
+// defaultDuration is the session duration when neither the config nor the SAML assertion sets one
+const defaultDuration = 3600
+
+// SessionDuration returns the session duration for a role, the duration configured for the role's
+// account takes precedence over the global one. Without a configured duration the duration requested
+// by the SAML assertion is used.
+func SessionDuration(conf Config, role *SAMLAssertionRole) int64 {
+	if account, ok := GetAccount(conf, role.AccountID); ok && account.Duration != 0 {
+		return int64(account.Duration)
+	}
+	if conf.Duration != 0 {
+		return int64(conf.Duration)
+	}
+	if role.SessionDuration != 0 {
+		return role.SessionDuration
+	}
+	return defaultDuration
+}
+
+// LegacyToken reports whether the legacy aws_security_token is stored for a role, the setting of the
+// role's account, enabled or disabled, takes precedence over the global one
+func LegacyToken(conf Config, role *SAMLAssertionRole) bool {
+	if account, ok := GetAccount(conf, role.AccountID); ok && account.LegacyToken != nil {
+		return *account.LegacyToken
+	}
+	return conf.LegacyToken
+}
+
+// defaultLoginAttempts is the number of times the password or one-time password is asked
+const defaultLoginAttempts = 3
+
+// LoginAttempts returns the number of times the user may enter the password or one-time password
+func LoginAttempts(conf Config) int {
+	if conf.LoginAttempts <= 0 {
+		return defaultLoginAttempts
+	}
+	return conf.LoginAttempts
+}
+
 // GetAccountsForEnvironment search an environment's detail for a given environment name
 func GetAccountsForEnvironment(conf Config, environment string) []string {
 	var accounts []string
@@ -0,0 +1,63 @@
+package masl
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveRole(t *testing.T) {
+	conf := Config{DefaultRole: "ReadOnlyAccess", Accounts: Accounts{
+		{ID: "111111111111", DefaultRole: "ops", RoleAliases: map[string]string{"ops": "Operator", "ro": "ReadOnly"}},
+		{ID: "222222222222"},
+	}}
+	assert.Equal(t, "Operator", ResolveRole(conf, "111111111111", ""))
+	assert.Equal(t, "ReadOnly", ResolveRole(conf, "111111111111", "RO"))
+	assert.Equal(t, "admin", ResolveRole(conf, "111111111111", "admin"))
+	assert.Equal(t, "ReadOnlyAccess", ResolveRole(conf, "222222222222", ""))
+	assert.Equal(t, "ro", ResolveRole(conf, "222222222222", "ro"))
+}
+
+func TestParseSAMLAssertionRoleAlias(t *testing.T) {
+	accounts := Accounts{
+		{ID: "111111111111", RoleAliases: map[string]string{"ro": "ReadOnly"}},
+		{ID: "222222222222", RoleAliases: map[string]string{"ro": "ViewOnly"}},
+	}
+	assertion := testRoleAssertion(
+		"arn:aws:iam::111111111111:role/ReadOnly,arn:aws:iam::111111111111:saml-provider/onelogin",
+		"arn:aws:iam::111111111111:role/admin,arn:aws:iam::111111111111:saml-provider/onelogin",
+		"arn:aws:iam::222222222222:role/ViewOnly,arn:aws:iam::222222222222:saml-provider/onelogin",
+		"arn:aws:iam::333333333333:role/ro,arn:aws:iam::333333333333:saml-provider/onelogin")
+
+	roles, err := ParseSAMLAssertion(assertion, accounts, nil, "ro")
+	assert.NoError(t, err)
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.AccountID+":"+role.RoleName)
+	}
+	assert.ElementsMatch(t, []string{"111111111111:ReadOnly", "222222222222:ViewOnly", "333333333333:ro"}, names)
+}
+
+func TestLegacyToken(t *testing.T) {
+	var conf Config
+	_, err := toml.Decode(`
+LegacyToken = true
+
+[[Accounts]]
+ID = '111111111111'
+LegacyToken = false
+
+[[Accounts]]
+ID = '222222222222'
+`, &conf)
+	assert.NoError(t, err)
+	assert.False(t, LegacyToken(conf, &SAMLAssertionRole{AccountID: "111111111111"}))
+	assert.True(t, LegacyToken(conf, &SAMLAssertionRole{AccountID: "222222222222"}))
+
+	conf.LegacyToken = false
+	enabled := true
+	conf.Accounts[1].LegacyToken = &enabled
+	assert.True(t, LegacyToken(conf, &SAMLAssertionRole{AccountID: "222222222222"}))
+	assert.False(t, LegacyToken(conf, &SAMLAssertionRole{AccountID: "333333333333"}))
+}
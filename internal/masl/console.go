@@ -0,0 +1,120 @@
+package masl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// partitionConsoles the federation endpoint and console of every partition
+var partitionConsoles = map[string]struct {
+	federation string
+	console    string
+}{
+	endpoints.AwsPartitionID: {"https://signin.aws.amazon.com/federation",
+		"https://console.aws.amazon.com/"},
+	endpoints.AwsCnPartitionID: {"https://signin.amazonaws.cn/federation",
+		"https://console.amazonaws.cn/"},
+	endpoints.AwsUsGovPartitionID: {"https://signin.amazonaws-us-gov.com/federation",
+		"https://console.amazonaws-us-gov.com/"},
+}
+
+// consoleIssuer is shown on the console's sign-out page
+const consoleIssuer = "masl"
+
+type federationSession struct {
+	SessionID    string `json:"sessionId"`
+	SessionKey   string `json:"sessionKey"`
+	SessionToken string `json:"sessionToken"`
+}
+
+type signinTokenResponse struct {
+	SigninToken string `json:"SigninToken"`
+}
+
+// FederationURL returns the AWS federation endpoint for a role, the configured FederationURL takes
+// precedence over the endpoint of the role's partition
+func FederationURL(conf Config, role *SAMLAssertionRole) string {
+	if conf.FederationURL != "" {
+		return conf.FederationURL
+	}
+	return partitionConsoles[partition(role)].federation
+}
+
+// ConsoleDestination returns the console page to open after signing in. The destination is either
+// a path on the console of the role's partition or a complete URL, the region is added when set.
+func ConsoleDestination(role *SAMLAssertionRole, destination string, region string) (string, error) {
+	if !strings.HasPrefix(destination, "https://") && !strings.HasPrefix(destination, "http://") {
+		destination = partitionConsoles[partition(role)].console + strings.TrimPrefix(destination, "/")
+	}
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("invalid console destination [%s]: %s", destination, err)
+	}
+	if region != "" {
+		query := destinationURL.Query()
+		query.Set("region", region)
+		destinationURL.RawQuery = query.Encode()
+	}
+	return destinationURL.String(), nil
+}
+
+// ConsoleURL exchanges the role credentials for a sign-in token at the AWS federation endpoint and
+// returns the URL to sign in to the AWS Management Console. The URL is valid for 15 minutes.
+func (client *Client) ConsoleURL(conf Config, output *sts.AssumeRoleWithSAMLOutput,
+	role *SAMLAssertionRole, destination string, region string) (string, error) {
+
+	session, err := json.Marshal(federationSession{
+		SessionID:    *output.Credentials.AccessKeyId,
+		SessionKey:   *output.Credentials.SecretAccessKey,
+		SessionToken: *output.Credentials.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	federation := FederationURL(conf, role)
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+	resp, err := client.HTTPClient.Get(federation + "?" + query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrFederation, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrFederation, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s returned %s", ErrFederation, federation, resp.Status)
+	}
+	var signinToken signinTokenResponse
+	if err := json.Unmarshal(body, &signinToken); err != nil || signinToken.SigninToken == "" {
+		return "", fmt.Errorf("%w: %s didn't return a sign-in token", ErrFederation, federation)
+	}
+
+	destination, err = ConsoleDestination(role, destination, region)
+	if err != nil {
+		return "", err
+	}
+	query = url.Values{}
+	query.Set("Action", "login")
+	query.Set("Issuer", consoleIssuer)
+	query.Set("Destination", destination)
+	query.Set("SigninToken", signinToken.SigninToken)
+	return federation + "?" + query.Encode(), nil
+}
+
+// ConsoleURL returns the AWS Management Console sign-in URL using the DefaultClient
+func ConsoleURL(conf Config, output *sts.AssumeRoleWithSAMLOutput, role *SAMLAssertionRole,
+	destination string, region string) (string, error) {
+	return DefaultClient.ConsoleURL(conf, output, role, destination, region)
+}
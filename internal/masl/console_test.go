@@ -0,0 +1,56 @@
+package masl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleURL(t *testing.T) {
+	federation := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := federationSession{}
+		assert.Equal(t, "getSigninToken", r.URL.Query().Get("Action"))
+		assert.NoError(t, json.Unmarshal([]byte(r.URL.Query().Get("Session")), &session))
+		if session.SessionID != "CURRENT" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, "SECRET", session.SessionKey)
+		assert.Equal(t, "TOKEN", session.SessionToken)
+		writeJSON(w, signinTokenResponse{SigninToken: "SIGNIN"})
+	}))
+	defer federation.Close()
+
+	conf := Config{FederationURL: federation.URL + "/federation"}
+	role := &SAMLAssertionRole{Partition: "aws-cn"}
+	signinURL, err := NewClient().ConsoleURL(conf, testOutput("CURRENT", time.Now().Add(time.Hour)), role,
+		"/ec2/home", "cn-northwest-1")
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(signinURL)
+	assert.NoError(t, err)
+	assert.Equal(t, federation.URL+"/federation", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "login", parsed.Query().Get("Action"))
+	assert.Equal(t, "SIGNIN", parsed.Query().Get("SigninToken"))
+	assert.Equal(t, "https://console.amazonaws.cn/ec2/home?region=cn-northwest-1", parsed.Query().Get("Destination"))
+
+	_, err = NewClient().ConsoleURL(conf, testOutput("EXPIRED", time.Now()), role, "", "")
+	assert.True(t, errors.Is(err, ErrFederation))
+}
+
+func TestConsoleDestination(t *testing.T) {
+	destination, err := ConsoleDestination(&SAMLAssertionRole{}, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://console.aws.amazon.com/", destination)
+
+	destination, err = ConsoleDestination(&SAMLAssertionRole{Partition: "aws-us-gov"},
+		"https://console.amazonaws-us-gov.com/s3/home?tab=buckets", "us-gov-east-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://console.amazonaws-us-gov.com/s3/home?region=us-gov-east-1&tab=buckets", destination)
+}
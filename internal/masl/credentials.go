@@ -0,0 +1,34 @@
+package masl
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// credentialProcessVersion is the only payload version supported by the AWS SDKs
+const credentialProcessVersion = 1
+
+// CredentialProcessOutput represents the document an AWS credential_process has to print
+//
+// See https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-sourcing-external.html
+type CredentialProcessOutput struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// CredentialProcess converts the STS credentials into a credential_process JSON document
+func CredentialProcess(assertionOutput *sts.AssumeRoleWithSAMLOutput) ([]byte, error) {
+	credentials := assertionOutput.Credentials
+	return json.Marshal(CredentialProcessOutput{
+		Version:         credentialProcessVersion,
+		AccessKeyID:     *credentials.AccessKeyId,
+		SecretAccessKey: *credentials.SecretAccessKey,
+		SessionToken:    *credentials.SessionToken,
+		Expiration:      credentials.Expiration.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,17 @@
+package masl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialProcess(t *testing.T) {
+	// The AWS SDKs only accept version 1 and an RFC 3339 expiration, masl stores it in UTC
+	expiration := time.Date(2021, 12, 24, 13, 30, 0, 0, time.FixedZone("CET", 3600))
+	document, err := CredentialProcess(testOutput("AKID", expiration))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Version":1,"AccessKeyId":"AKID","SecretAccessKey":"SECRET","SessionToken":"TOKEN",
+		"Expiration":"2021-12-24T12:30:00Z"}`, string(document))
+}
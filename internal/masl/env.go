@@ -0,0 +1,94 @@
+package masl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// EnvVar represents an environment variable
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// CredentialEnv returns the environment variables exposing the STS credentials to AWS tools
+func CredentialEnv(assertionOutput *sts.AssumeRoleWithSAMLOutput, region string) []EnvVar {
+	credentials := assertionOutput.Credentials
+	expiration := credentials.Expiration.UTC().Format(time.RFC3339)
+	return []EnvVar{
+		{"AWS_ACCESS_KEY_ID", *credentials.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", *credentials.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", *credentials.SessionToken},
+		{"AWS_REGION", region},
+		{"AWS_DEFAULT_REGION", region},
+		{"AWS_CREDENTIAL_EXPIRATION", expiration},
+		{"AWS_SESSION_EXPIRATION", expiration},
+	}
+}
+
+// The shells supported by ShellExport
+const (
+	ShellPOSIX      = "posix"
+	ShellFish       = "fish"
+	ShellPowerShell = "powershell"
+	ShellCmd        = "cmd"
+)
+
+// NormalizeShell maps a shell name (bash, zsh, pwsh, ...) to one of the supported shells
+func NormalizeShell(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(filepath.Base(name), ".exe"))
+	switch name {
+	case "sh", "bash", "zsh", "ksh", "dash", "ash", ShellPOSIX:
+		return ShellPOSIX, nil
+	case ShellFish:
+		return ShellFish, nil
+	case "pwsh", ShellPowerShell:
+		return ShellPowerShell, nil
+	case ShellCmd:
+		return ShellCmd, nil
+	}
+	return "", fmt.Errorf("unsupported shell [%s], use one of: %s, %s, %s, %s", name,
+		ShellPOSIX, ShellFish, ShellPowerShell, ShellCmd)
+}
+
+// DetectShell guesses the shell masl was started from
+func DetectShell() string {
+	if runtime.GOOS == "windows" {
+		// cmd.exe sets PROMPT, PowerShell doesn't
+		if os.Getenv("PROMPT") != "" {
+			return ShellCmd
+		}
+		return ShellPowerShell
+	}
+	if shell, err := NormalizeShell(os.Getenv("SHELL")); err == nil {
+		return shell
+	}
+	return ShellPOSIX
+}
+
+// ShellExport formats the environment variables as statements to be evaluated by a shell
+func ShellExport(shell string, vars []EnvVar) string {
+	var builder strings.Builder
+	for _, envVar := range vars {
+		switch shell {
+		case ShellFish:
+			value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(envVar.Value)
+			fmt.Fprintf(&builder, "set -gx %s '%s';\n", envVar.Name, value)
+		case ShellPowerShell:
+			value := strings.Replace(envVar.Value, `'`, `''`, -1)
+			fmt.Fprintf(&builder, "$Env:%s = '%s'\n", envVar.Name, value)
+		case ShellCmd:
+			fmt.Fprintf(&builder, "set %s=%s\n", envVar.Name, envVar.Value)
+		default:
+			value := strings.Replace(envVar.Value, `'`, `'\''`, -1)
+			fmt.Fprintf(&builder, "export %s='%s'\n", envVar.Name, value)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,31 @@
+package masl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellExport(t *testing.T) {
+	vars := []EnvVar{{"AWS_ACCESS_KEY_ID", "AKID"}, {"AWS_SESSION_TOKEN", `it's\`}}
+
+	assert.Equal(t, "export AWS_ACCESS_KEY_ID='AKID'\nexport AWS_SESSION_TOKEN='it'\\''s\\'\n",
+		ShellExport(ShellPOSIX, vars))
+	assert.Equal(t, "set -gx AWS_ACCESS_KEY_ID 'AKID';\nset -gx AWS_SESSION_TOKEN 'it\\'s\\\\';\n",
+		ShellExport(ShellFish, vars))
+	assert.Equal(t, "$Env:AWS_ACCESS_KEY_ID = 'AKID'\n$Env:AWS_SESSION_TOKEN = 'it''s\\'\n",
+		ShellExport(ShellPowerShell, vars))
+	assert.Equal(t, "set AWS_ACCESS_KEY_ID=AKID\nset AWS_SESSION_TOKEN=it's\\\n",
+		ShellExport(ShellCmd, vars))
+}
+
+func TestNormalizeShell(t *testing.T) {
+	for name, shell := range map[string]string{"/bin/bash": ShellPOSIX, "/usr/bin/zsh": ShellPOSIX,
+		"/usr/local/bin/fish": ShellFish, "pwsh": ShellPowerShell, "cmd.exe": ShellCmd} {
+		normalized, err := NormalizeShell(name)
+		assert.NoError(t, err)
+		assert.Equal(t, shell, normalized, name)
+	}
+	_, err := NormalizeShell("tcsh")
+	assert.Error(t, err)
+}
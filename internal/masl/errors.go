@@ -0,0 +1,41 @@
+package masl
+
+import "errors"
+
+// The errors returned by masl, use errors.Is to test for them as they're usually wrapped with
+// more details.
+var (
+	// ErrConfigMissing the masl config file doesn't exist
+	ErrConfigMissing = errors.New("masl config file not found")
+	// ErrInvalidConfig the masl config file can't be parsed
+	ErrInvalidConfig = errors.New("invalid masl config file")
+	// ErrOneLoginAPI the OneLogin API couldn't be reached or returned an unexpected response
+	ErrOneLoginAPI = errors.New("OneLogin API request failed")
+	// ErrAPIToken OneLogin refused to hand out an API access token
+	ErrAPIToken = errors.New("unable to acquire a OneLogin access token (check config.toml)")
+	// ErrInvalidCredentials OneLogin rejected the username or password
+	ErrInvalidCredentials = errors.New("invalid OneLogin credentials")
+	// ErrAccountLocked OneLogin locked the account, usually after too many failed logins
+	ErrAccountLocked = errors.New("OneLogin account is locked")
+	// ErrMFARejected the MFA verification was rejected
+	ErrMFARejected = errors.New("MFA verification failed")
+	// ErrMFATimeout the MFA push notification wasn't approved in time
+	ErrMFATimeout = errors.New("timed out waiting for the push notification to be approved")
+	// ErrStateTokenExpired the state token of the MFA verification expired, a new SAML assertion
+	// has to be requested
+	ErrStateTokenExpired = errors.New("OneLogin MFA session expired")
+	// ErrInvalidAssertion the SAML assertion can't be parsed
+	ErrInvalidAssertion = errors.New("invalid SAML assertion")
+	// ErrNoRoles the SAML assertion doesn't contain any (matching) AWS roles
+	ErrNoRoles = errors.New("no AWS roles available")
+	// ErrSTSDenied AWS STS refused to assume the role
+	ErrSTSDenied = errors.New("AWS STS denied the role")
+	// ErrFederation the AWS federation endpoint refused to hand out a console sign-in token
+	ErrFederation = errors.New("AWS console sign-in failed")
+	// ErrCredentialsFile the AWS credentials file can't be updated
+	ErrCredentialsFile = errors.New("unable to update the AWS credentials file")
+	// ErrSecretNotFound the secret isn't available in the secret store
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrAWSConfigFile the AWS config file can't be updated
+	ErrAWSConfigFile = errors.New("unable to update the AWS config file")
+)
@@ -0,0 +1,50 @@
+package masl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// maslPath returns the path of a file in the .masl directory
+func maslPath(homeDir string, name string) string {
+	return homeDir + string(os.PathSeparator) + ".masl" + string(os.PathSeparator) + name
+}
+
+// readJSONFile decodes a JSON file of the .masl directory into target. false is returned when the
+// file doesn't exist or is corrupt, a corrupt file is not fatal as it's overwritten on the next save.
+func readJSONFile(homeDir string, name string, target interface{}) bool {
+	data, err := ioutil.ReadFile(maslPath(homeDir, name))
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		logger.Warn(err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSONFile replaces a file of the .masl directory at once, only readable by the user. Parallel
+// runs never read a partially written file.
+func writeJSONFile(homeDir string, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(homeDir+string(os.PathSeparator)+".masl", name)
+	if err != nil {
+		return err
+	}
+	// TempFile creates the file only readable by the user
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), maslPath(homeDir, name))
+}
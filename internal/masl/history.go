@@ -0,0 +1,86 @@
+package masl
+
+import (
+	"sort"
+	"time"
+)
+
+const historyFileName = "history.json"
+
+// HistoryEntry records how often and when a role was selected
+type HistoryEntry struct {
+	AccountID string    `json:"accountId"`
+	RoleArn   string    `json:"roleArn"`
+	RoleName  string    `json:"roleName"`
+	Count     int       `json:"count"`
+	LastUsed  time.Time `json:"lastUsed"`
+}
+
+// roleHistory holds the history entries keyed by account ID and role ARN
+type roleHistory map[string]HistoryEntry
+
+// frecency scores an entry on how often and how recently the role was selected
+func (entry HistoryEntry) frecency(now time.Time) float64 {
+	age := now.Sub(entry.LastUsed)
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 1
+	case age < 30*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(entry.Count) * weight
+}
+
+func readHistory(homeDir string) roleHistory {
+	history := roleHistory{}
+	if !readJSONFile(homeDir, historyFileName, &history) || history == nil {
+		return roleHistory{}
+	}
+	return history
+}
+
+// RecordRole adds the selection of a role to the history
+func RecordRole(homeDir string, role *SAMLAssertionRole) error {
+	history := readHistory(homeDir)
+	key := cacheKey(role.AccountID, role.RoleArn)
+	entry := history[key]
+	entry.AccountID = role.AccountID
+	entry.RoleArn = role.RoleArn
+	entry.RoleName = role.RoleName
+	entry.Count++
+	entry.LastUsed = time.Now()
+	history[key] = entry
+
+	return writeJSONFile(homeDir, historyFileName, history)
+}
+
+// LastRole returns the most recently selected role
+func LastRole(homeDir string) (HistoryEntry, bool) {
+	var last HistoryEntry
+	found := false
+	for _, entry := range readHistory(homeDir) {
+		if !found || entry.LastUsed.After(last.LastUsed) {
+			last = entry
+			found = true
+		}
+	}
+	return last, found
+}
+
+// SortByHistory sorts the frequently and recently selected roles first, the order of the other
+// roles is kept
+func SortByHistory(homeDir string, roles []*SAMLAssertionRole) {
+	history := readHistory(homeDir)
+	now := time.Now()
+	score := func(role *SAMLAssertionRole) float64 {
+		return history[cacheKey(role.AccountID, role.RoleArn)].frecency(now)
+	}
+	sort.SliceStable(roles, func(i, j int) bool {
+		return score(roles[i]) > score(roles[j])
+	})
+}
@@ -0,0 +1,40 @@
+package masl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleHistory(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".masl"), 0700))
+
+	_, found := LastRole(homeDir)
+	assert.False(t, found)
+
+	admin := &SAMLAssertionRole{AccountID: "111111111111", RoleArn: "arn:aws:iam::111111111111:role/admin",
+		RoleName: "admin"}
+	reader := &SAMLAssertionRole{AccountID: "222222222222", RoleArn: "arn:aws:iam::222222222222:role/reader",
+		RoleName: "reader"}
+	other := &SAMLAssertionRole{AccountID: "333333333333", RoleArn: "arn:aws:iam::333333333333:role/other",
+		RoleName: "other"}
+
+	assert.NoError(t, RecordRole(homeDir, reader))
+	assert.NoError(t, RecordRole(homeDir, reader))
+	assert.NoError(t, RecordRole(homeDir, admin))
+
+	last, found := LastRole(homeDir)
+	assert.True(t, found)
+	assert.Equal(t, "111111111111", last.AccountID)
+	assert.Equal(t, "admin", last.RoleName)
+
+	roles := []*SAMLAssertionRole{other, admin, reader}
+	SortByHistory(homeDir, roles)
+	assert.Equal(t, []*SAMLAssertionRole{reader, admin, other}, roles)
+}
@@ -0,0 +1,203 @@
+package masl
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The paths of the EC2 instance metadata service API emulated by the IMDSHandler
+const (
+	imdsTokenPath            = "/latest/api/token"
+	imdsMetaDataPath         = "/latest/meta-data/"
+	imdsSecurityCredentials  = "/latest/meta-data/iam/security-credentials/"
+	imdsIAMInfoPath          = "/latest/meta-data/iam/info"
+	imdsRegionPath           = "/latest/meta-data/placement/region"
+	imdsAvailabilityZonePath = "/latest/meta-data/placement/availability-zone"
+	imdsIdentityDocumentPath = "/latest/dynamic/instance-identity/document"
+	imdsMaxTokenTTL          = 21600
+	imdsInstanceID           = "i-0000000000masl"
+)
+
+// imdsCredentials represents the IMDS security credentials response
+//
+// See https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/instancedata-data-retrieval.html
+type imdsCredentials struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// imdsIAMInfo represents the IMDS IAM info response
+type imdsIAMInfo struct {
+	Code               string `json:"Code"`
+	LastUpdated        string `json:"LastUpdated"`
+	InstanceProfileArn string `json:"InstanceProfileArn"`
+	InstanceProfileID  string `json:"InstanceProfileId"`
+}
+
+// imdsIdentityDocument represents the IMDS instance identity document
+type imdsIdentityDocument struct {
+	AccountID        string `json:"accountId"`
+	Architecture     string `json:"architecture"`
+	AvailabilityZone string `json:"availabilityZone"`
+	ImageID          string `json:"imageId"`
+	InstanceID       string `json:"instanceId"`
+	InstanceType     string `json:"instanceType"`
+	PendingTime      string `json:"pendingTime"`
+	PrivateIP        string `json:"privateIp"`
+	Region           string `json:"region"`
+	Version          string `json:"version"`
+}
+
+// imdsTokens keeps track of the session tokens handed out by the IMDSHandler
+type imdsTokens struct {
+	mutex  sync.Mutex
+	tokens map[string]time.Time
+}
+
+func (tokens *imdsTokens) create(ttl time.Duration) (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	encoded := hex.EncodeToString(token)
+
+	tokens.mutex.Lock()
+	defer tokens.mutex.Unlock()
+	now := time.Now()
+	for existing, expiry := range tokens.tokens {
+		if !expiry.After(now) {
+			delete(tokens.tokens, existing)
+		}
+	}
+	tokens.tokens[encoded] = now.Add(ttl)
+	return encoded, nil
+}
+
+func (tokens *imdsTokens) valid(token string) bool {
+	tokens.mutex.Lock()
+	defer tokens.mutex.Unlock()
+	expiry, ok := tokens.tokens[token]
+	return ok && expiry.After(time.Now())
+}
+
+// IMDSHandler serves the credentials of the source through an emulation of the EC2 instance
+// metadata service (IMDSv2). The role is exposed under its role name.
+func IMDSHandler(source *CredentialSource, region string) http.Handler {
+	tokens := &imdsTokens{tokens: map[string]time.Time{}}
+	role := source.Role()
+	started := time.Now().UTC().Format(time.RFC3339)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(imdsTokenPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		// Like EC2, refuse requests which went through a proxy
+		if r.Header.Get("X-Forwarded-For") != "" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		ttl, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+		if err != nil || ttl < 1 || ttl > imdsMaxTokenTTL {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		token, err := tokens.create(time.Duration(ttl) * time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(ttl))
+		fmt.Fprint(w, token)
+	})
+
+	metadata := http.NewServeMux()
+	metadata.HandleFunc(imdsMetaDataPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != imdsMetaDataPath {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "iam/\nplacement/")
+	})
+	metadata.HandleFunc(imdsSecurityCredentials, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, imdsSecurityCredentials)
+		if name == "" {
+			fmt.Fprint(w, role.RoleName)
+			return
+		}
+		if name != role.RoleName {
+			http.NotFound(w, r)
+			return
+		}
+		output, err := source.Credentials()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, imdsCredentials{
+			Code:            "Success",
+			LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+			Type:            "AWS-HMAC",
+			AccessKeyID:     *output.Credentials.AccessKeyId,
+			SecretAccessKey: *output.Credentials.SecretAccessKey,
+			Token:           *output.Credentials.SessionToken,
+			Expiration:      output.Credentials.Expiration.UTC().Format(time.RFC3339),
+		})
+	})
+	metadata.HandleFunc(imdsIAMInfoPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, imdsIAMInfo{
+			Code:        "Success",
+			LastUpdated: started,
+			InstanceProfileArn: fmt.Sprintf("arn:%s:iam::%s:instance-profile%s%s", role.Partition,
+				role.AccountID, role.Path, role.RoleName),
+			InstanceProfileID: "AIPAMASL",
+		})
+	})
+	metadata.HandleFunc(imdsRegionPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, region)
+	})
+	metadata.HandleFunc(imdsAvailabilityZonePath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, region+"a")
+	})
+	metadata.HandleFunc(imdsIdentityDocumentPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, imdsIdentityDocument{
+			AccountID:        role.AccountID,
+			Architecture:     "x86_64",
+			AvailabilityZone: region + "a",
+			ImageID:          "ami-00000000",
+			InstanceID:       imdsInstanceID,
+			InstanceType:     "t3.micro",
+			PendingTime:      started,
+			PrivateIP:        "127.0.0.1",
+			Region:           region,
+			Version:          "2017-09-30",
+		})
+	})
+
+	// All metadata requires a valid session token (IMDSv2)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if !tokens.valid(r.Header.Get("X-aws-ec2-metadata-token")) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		metadata.ServeHTTP(w, r)
+	})
+	return mux
+}
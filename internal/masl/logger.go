@@ -19,7 +19,8 @@ func GetLogger(level string) *zap.Logger {
 		usr, err := user.Current()
 		if err != nil {
 			fmt.Printf("\n%s", err.Error())
-			os.Exit(1)
+			zapLogger, _ = zap.NewProduction()
+			return
 		}
 		var zapLevel zap.AtomicLevel
 		if level == "debug" {
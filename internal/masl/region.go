@@ -0,0 +1,76 @@
+package masl
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// partitionRegions the region used for a partition when no region is configured
+var partitionRegions = map[string]string{
+	endpoints.AwsPartitionID:      endpoints.UsEast1RegionID,
+	endpoints.AwsCnPartitionID:    endpoints.CnNorth1RegionID,
+	endpoints.AwsUsGovPartitionID: endpoints.UsGovWest1RegionID,
+}
+
+// Region returns the AWS region for a role. The configured region takes precedence, otherwise the
+// AWS_REGION or the AWS_DEFAULT_REGION environment variable is used when it belongs to the role's
+// partition, falling back to the partition's default region.
+func Region(conf Config, role *SAMLAssertionRole) string {
+	if region := ConfiguredRegion(conf, role); region != "" {
+		return region
+	}
+	partition := partition(role)
+	for _, variable := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(variable); region != "" && inPartition(region, partition) {
+			return region
+		}
+	}
+	return partitionRegions[partition]
+}
+
+// ConfiguredRegion returns the region configured for a role, if any. The region of the role's
+// account takes precedence over the global one, which is only used in its own partition.
+func ConfiguredRegion(conf Config, role *SAMLAssertionRole) string {
+	if account, ok := GetAccount(conf, role.AccountID); ok && account.Region != "" {
+		return account.Region
+	}
+	if conf.Region != "" && inPartition(conf.Region, partition(role)) {
+		return conf.Region
+	}
+	return ""
+}
+
+// inPartition test if a region belongs to the partition
+func inPartition(region string, partition string) bool {
+	regionPartition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	return ok && regionPartition.ID() == partition
+}
+
+// partition returns the partition of a role, roles without a partition are in the aws partition
+func partition(role *SAMLAssertionRole) string {
+	if role.Partition == "" {
+		return endpoints.AwsPartitionID
+	}
+	return role.Partition
+}
+
+// STSEndpoint returns the configured STS endpoint for a role, if any
+func STSEndpoint(conf Config, role *SAMLAssertionRole) string {
+	if account, ok := GetAccount(conf, role.AccountID); ok && account.STSEndpoint != "" {
+		return account.STSEndpoint
+	}
+	return conf.STSEndpoint
+}
+
+// stsConfig returns the AWS config to reach the regional STS endpoint of a role's partition
+func stsConfig(conf Config, role *SAMLAssertionRole) *aws.Config {
+	cfg := aws.NewConfig().
+		WithRegion(Region(conf, role)).
+		WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
+	if endpoint := STSEndpoint(conf, role); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint)
+	}
+	return cfg
+}
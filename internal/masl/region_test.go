@@ -0,0 +1,38 @@
+package masl
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegion(t *testing.T) {
+	os.Unsetenv("AWS_REGION")
+	os.Unsetenv("AWS_DEFAULT_REGION")
+
+	conf := Config{Accounts: Accounts{{ID: "123456789012", Region: "eu-central-1"}}}
+	assert.Equal(t, "eu-central-1", Region(conf, &SAMLAssertionRole{AccountID: "123456789012"}))
+	assert.Equal(t, "us-east-1", Region(conf, &SAMLAssertionRole{AccountID: "210987654321"}))
+	assert.Equal(t, "cn-north-1", Region(conf, &SAMLAssertionRole{Partition: "aws-cn"}))
+
+	os.Setenv("AWS_REGION", "eu-west-1")
+	defer os.Unsetenv("AWS_REGION")
+	assert.Equal(t, "eu-west-1", Region(conf, &SAMLAssertionRole{Partition: "aws"}))
+	assert.Equal(t, "us-gov-west-1", Region(conf, &SAMLAssertionRole{Partition: "aws-us-gov"}))
+
+	conf.Region = "us-gov-east-1"
+	assert.Equal(t, "us-gov-east-1", Region(conf, &SAMLAssertionRole{Partition: "aws-us-gov"}))
+
+	// The global region isn't used outside its partition
+	conf.Region = "eu-west-3"
+	assert.Equal(t, "eu-west-3", Region(conf, &SAMLAssertionRole{Partition: "aws"}))
+	assert.Equal(t, "us-gov-west-1", Region(conf, &SAMLAssertionRole{Partition: "aws-us-gov"}))
+	assert.Equal(t, "cn-north-1", Region(conf, &SAMLAssertionRole{Partition: "aws-cn"}))
+
+	assert.Equal(t, "eu-west-3", ConfiguredRegion(conf, &SAMLAssertionRole{Partition: "aws"}))
+	assert.Equal(t, "", ConfiguredRegion(conf, &SAMLAssertionRole{Partition: "aws-cn"}))
+	assert.Equal(t, "eu-central-1", ConfiguredRegion(conf, &SAMLAssertionRole{AccountID: "123456789012"}))
+	conf.Region = ""
+	assert.Equal(t, "", ConfiguredRegion(conf, &SAMLAssertionRole{Partition: "aws"}))
+}
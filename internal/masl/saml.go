@@ -16,7 +16,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"gopkg.in/ini.v1"
 )
@@ -24,6 +24,7 @@ import (
 /* #nosec */
 const (
 	generateTokenAPI = "auth/oauth2/token"
+	revokeTokenAPI   = "auth/oauth2/revoke"
 	samlAssertionAPI = "api/1/saml_assertion"
 	verifyFactorAPI  = "api/1/saml_assertion/verify_factor"
 )
@@ -91,6 +92,7 @@ type samlAssertionResponseMFA struct {
 type SAMLAssertionData struct {
 	MFARequired bool
 	StateToken  string
+	CallbackURL string
 	Data        string
 	Devices     []MFADevice
 }
@@ -103,10 +105,11 @@ type MFADevice struct {
 
 // VerifyMFARequest represents the OneLogin Verify MFA request
 type VerifyMFARequest struct {
-	AppID      string `json:"app_id"`
-	OtpToken   string `json:"otp_token"`
-	DeviceID   string `json:"device_id"`
-	StateToken string `json:"state_token"`
+	AppID       string `json:"app_id"`
+	OtpToken    string `json:"otp_token,omitempty"`
+	DeviceID    string `json:"device_id"`
+	StateToken  string `json:"state_token"`
+	DoNotNotify bool   `json:"do_not_notify,omitempty"`
 }
 
 // VerifyMFAResponse represents the OneLogin Verify MFA response
@@ -125,9 +128,18 @@ type SAMLAssertionRole struct {
 	ID                     int
 	PrincipalArn           string
 	RoleArn                string
+	Partition              string
+	Path                   string
+	RoleName               string
 	AccountID              string
 	AccountName            string
 	EnvironmentIndependent bool
+	// SessionDuration is the session duration requested by the SAML assertion, if any
+	SessionDuration int64 `json:",omitempty"`
+	// Source is the SAML role a chained role is assumed from
+	Source *SAMLAssertionRole `json:",omitempty"`
+	// Chain lists the roles assumed in turn after the Source role
+	Chain []Chain `json:",omitempty"`
 }
 
 // RolesByName roles sorted by account name
@@ -139,8 +151,6 @@ func (byName RolesByName) Less(i, j int) bool {
 	return strings.Compare(byName[i].AccountName, byName[j].AccountName) == -1
 }
 
-var httpClient = &http.Client{Timeout: 10 * time.Second}
-
 func logRequest(req *http.Request) {
 	// dump, _ := httputil.DumpRequest(req, true)
 	//TODO: shame on me, filter passwords from the requests before logging them!
@@ -152,187 +162,453 @@ func logResponse(resp *http.Response) {
 	logger.Debug(string(dump))
 }
 
+// statusResponse represents the status wrapper all OneLogin API v1 responses share
+type statusResponse struct {
+	Status struct {
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Error   bool   `json:"error"`
+	} `json:"status"`
+}
+
 // GenerateToken Call to https://developers.onelogin.com/api-docs/1/oauth20-tokens/generate-tokens
-func GenerateToken(conf Config) string {
+func (client *Client) GenerateToken(conf Config) (string, error) {
+	token, err := client.generateToken(conf)
+	return token.AccessToken, err
+}
 
-	url := conf.BaseURL + generateTokenAPI
-	requestBody := []byte(`{"grant_type":"client_credentials"}`)
-	auth := "client_id:" + conf.ClientID + ",client_secret:" + conf.ClientSecret
+func (client *Client) generateToken(conf Config) (APIToken, error) {
+	return client.requestToken(conf, clientAuth(conf), []byte(`{"grant_type":"client_credentials"}`))
+}
+
+// refreshToken Call to https://developers.onelogin.com/api-docs/1/oauth20-tokens/refresh-tokens
+func (client *Client) refreshToken(conf Config, token APIToken) (APIToken, error) {
+	requestBody, err := json.Marshal(map[string]string{
+		"grant_type":    "refresh_token",
+		"access_token":  token.AccessToken,
+		"refresh_token": token.RefreshToken})
+	if err != nil {
+		return APIToken{}, err
+	}
+	return client.requestToken(conf, "", requestBody)
+}
+
+func (client *Client) requestToken(conf Config, auth string, requestBody []byte) (APIToken, error) {
 
+	url := conf.BaseURL + generateTokenAPI
 	apiToken := APITokenResponse{}
-	httpRequest(url, auth, requestBody, &apiToken)
+	if err := client.httpRequest(url, auth, requestBody, &apiToken); err != nil {
+		return APIToken{}, err
+	}
 
-	//TODO: being a bit optimistic here ;)
-	if apiToken.Status.Code != 200 {
-		fmt.Printf("Unable to acquire an OneLogin access token (check config.toml): %s\n", apiToken.Status.Message)
-		os.Exit(0)
+	if apiToken.Status.Code != 200 || len(apiToken.Data) == 0 {
+		return APIToken{}, fmt.Errorf("%w: %s", ErrAPIToken, apiToken.Status.Message)
 	}
-	// logger.Debug(apiToken)
-	return apiToken.Data[0].AccessToken
+	data := apiToken.Data[0]
+	return APIToken{
+		AccessToken:  data.AccessToken,
+		RefreshToken: data.RefreshToken,
+		Expiration:   client.Clock.Now().Add(time.Duration(data.ExpiresIn) * time.Second),
+	}, nil
 }
 
-// SAMLAssertion Call to https://api.eu.onelogin.com/api/1/saml_assertion
-func SAMLAssertion(conf Config, password string, apiToken string) (SAMLAssertionData, error) {
+// revokeToken Call to https://developers.onelogin.com/api-docs/1/oauth20-tokens/revoke-tokens
+func (client *Client) revokeToken(conf Config, token APIToken) error {
+	requestBody, err := json.Marshal(map[string]string{"access_token": token.AccessToken})
+	if err != nil {
+		return err
+	}
+	status := statusResponse{}
+	if err := client.httpRequest(conf.BaseURL+revokeTokenAPI, clientAuth(conf), requestBody, &status); err != nil {
+		return err
+	}
+	if status.Status.Code != 200 {
+		return fmt.Errorf("%w: %s", ErrOneLoginAPI, status.Status.Message)
+	}
+	return nil
+}
+
+// clientAuth returns the authorization header authenticating masl with the OneLogin API credentials
+func clientAuth(conf Config) string {
+	return "client_id:" + conf.ClientID + ",client_secret:" + conf.ClientSecret
+}
+
+// SAMLAssertion Call to https://api.eu.onelogin.com/api/1/saml_assertion or, depending on the
+// APIVersion, https://api.eu.onelogin.com/api/2/saml_assertion
+func (client *Client) SAMLAssertion(conf Config, password string, apiToken string) (SAMLAssertionData, error) {
 
-	url := conf.BaseURL + samlAssertionAPI
 	requestBody, err := json.Marshal(SAMLAssertionRequest{
 		UsernameOrEmail: conf.Username,
 		Password:        password,
 		AppID:           conf.AppID,
 		Subdomain:       conf.Subdomain})
 	if err != nil {
-		logger.Fatal(err.Error())
+		return SAMLAssertionData{}, err
+	}
+	version, err := client.apiVersion(conf)
+	if err != nil {
+		return SAMLAssertionData{}, err
 	}
+	if version == APIVersion2 {
+		return client.samlAssertionV2(conf, requestBody, apiToken)
+	}
+
+	samlAssertionData, err := client.samlAssertionV1(conf, requestBody, apiToken)
+	var unavailable *apiUnavailableError
+	if errors.As(err, &unavailable) && detectAPIVersion(conf) {
+		logger.Sugar().Infof("%s, switching to OneLogin API v2.", err)
+		client.setAPIVersion(conf, APIVersion2)
+		return client.samlAssertionV2(conf, requestBody, apiToken)
+	}
+	return samlAssertionData, err
+}
+
+func (client *Client) samlAssertionV1(conf Config, requestBody []byte, apiToken string) (SAMLAssertionData, error) {
+
+	url := conf.BaseURL + samlAssertionAPI
 	auth := "bearer:" + apiToken
 
 	// Parse the raw body to determine if MFA is required
-	body := httpRequestRaw(url, auth, requestBody)
-	var rawData map[string]interface{}
-	if err := json.Unmarshal(body, &rawData); err != nil {
-		logger.Fatal(err.Error())
+	statusCode, body, err := client.httpResponse(url, auth, requestBody)
+	if err != nil {
+		return SAMLAssertionData{}, err
+	}
+	if statusCode == http.StatusNotFound || statusCode == http.StatusGone {
+		return SAMLAssertionData{}, &apiUnavailableError{url: url, statusCode: statusCode}
 	}
-	status := rawData["status"].(map[string]interface{})
-	message := status["message"].(string)
+	status := statusResponse{}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
+	}
+	message := status.Status.Message
 	logger.Info(message)
 
-	var samlData SAMLAssertionData
-	var samlErr error
-
-	code := int(status["code"].(float64))
-	if code == 200 {
-		if strings.EqualFold(message, "success") {
-			// MFA NOT Required
-			logger.Info("MFA not required")
-			assertionResponse := samlAssertionResponse{}
-			if err := json.Unmarshal(body, &assertionResponse); err != nil {
-				logger.Fatal(err.Error())
-			}
+	switch {
+	case status.Status.Code != 200:
+		return SAMLAssertionData{}, assertionError(status.Status.Code, status.Status.Type, message)
+	case strings.EqualFold(message, "success"):
+		// MFA NOT Required
+		logger.Info("MFA not required")
+		assertionResponse := samlAssertionResponse{}
+		if err := json.Unmarshal(body, &assertionResponse); err != nil {
+			return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
+		}
 
-			samlData = SAMLAssertionData{
-				MFARequired: false,
-				Data:        assertionResponse.Data,
-			}
-		} else {
-			assertionResponse := samlAssertionResponseMFA{}
+		return SAMLAssertionData{
+			MFARequired: false,
+			Data:        assertionResponse.Data,
+		}, nil
+	default:
+		// MFA token is required
+		assertionResponse := samlAssertionResponseMFA{}
+		if err := json.Unmarshal(body, &assertionResponse); err != nil {
+			return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
+		}
+		if len(assertionResponse.Data) == 0 {
+			return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, message)
+		}
 
-			// MFA token is required
-			// log.WithFields(logrus.Fields{
-			// 	"response": assertionResponse,
-			// }).Debug("Assertionresponse in case of  MFA")
+		return SAMLAssertionData{
+			MFARequired: true,
+			StateToken:  assertionResponse.Data[0].StateToken,
+			CallbackURL: assertionResponse.Data[0].CallbackURL,
+			Devices:     assertionResponse.Data[0].Devices,
+		}, nil
+	}
+}
 
-			if err := json.Unmarshal(body, &assertionResponse); err != nil {
-				logger.Fatal(err.Error())
-			}
+// assertionError returns the error matching the status of a failed SAML assertion request. A 401
+// is returned for invalid credentials as well as for a locked account or a rejected API token (an
+// "Authentication Failure" instead of "Authentication Failed"), only then the status type and message
+// tell them apart.
+func assertionError(code int, statusType string, message string) error {
+	var err error
+	switch {
+	case code == http.StatusLocked:
+		err = ErrAccountLocked
+	case statusMentions(statusType, message, "locked"):
+		err = ErrAccountLocked
+	case code == http.StatusUnauthorized && statusMentions(statusType, message, "authentication failure",
+		"invalid token", "access token"):
+		err = ErrAPIToken
+	case code == http.StatusUnauthorized:
+		err = ErrInvalidCredentials
+	default:
+		err = ErrOneLoginAPI
+	}
+	return fmt.Errorf("%w: %s", err, message)
+}
 
-			samlData = SAMLAssertionData{
-				MFARequired: true,
-				StateToken:  assertionResponse.Data[0].StateToken,
-				Devices:     assertionResponse.Data[0].Devices,
-			}
+// verifyError returns the error matching the status of a failed verify_factor request. An unknown
+// state token (404 or 410) has expired, a 400 or 401 is returned for an invalid state token as well
+// as for a rejected factor, only then the status type and message tell them apart.
+func verifyError(code int, statusType string, message string) error {
+	var err error
+	switch {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		err = ErrStateTokenExpired
+	case code == http.StatusLocked:
+		err = ErrAccountLocked
+	case statusMentions(statusType, message, "state_token", "state token"):
+		err = ErrStateTokenExpired
+	case statusMentions(statusType, message, "locked"):
+		err = ErrAccountLocked
+	default:
+		err = ErrMFARejected
+	}
+	return fmt.Errorf("%w: %s", err, message)
+}
+
+// statusMentions test if the status type or message of a failed OneLogin request contains one of
+// the words
+func statusMentions(statusType string, message string, words ...string) bool {
+	status := strings.ToLower(statusType + " " + message)
+	for _, word := range words {
+		if strings.Contains(status, word) {
+			return true
 		}
-	} else {
-		samlErr = errors.New(message)
 	}
-	return samlData, samlErr
+	return false
 }
 
-// VerifyMFA Call to https://api.eu.onelogin.com/api/1/saml_assertion/verify_factor
-func VerifyMFA(conf Config, deviceID int, stateToken string, otp string,
+// VerifyMFA Call to https://api.eu.onelogin.com/api/1/saml_assertion/verify_factor, or the v2
+// endpoint depending on the APIVersion
+func (client *Client) VerifyMFA(conf Config, deviceID int, stateToken string, otp string,
 	apiToken string) (string, error) {
 
-	url := conf.BaseURL + verifyFactorAPI
-	requestBody, err := json.Marshal(VerifyMFARequest{
+	version, err := client.apiVersion(conf)
+	if err != nil {
+		return "", err
+	}
+	samlData, _, err := client.verifyFactor(version, verifyFactorURL(conf, version), VerifyMFARequest{
 		AppID:      conf.AppID,
 		OtpToken:   otp,
 		DeviceID:   strconv.Itoa(deviceID),
-		StateToken: stateToken})
+		StateToken: stateToken}, apiToken)
+	return samlData, err
+}
+
+// verifyFactor verifies an MFA device with the verify_factor endpoint of the API version. An empty
+// SAML assertion is returned, next to the status message, while the verification is pending.
+func (client *Client) verifyFactor(version string, url string, request VerifyMFARequest,
+	apiToken string) (string, string, error) {
+
+	requestBody, err := json.Marshal(request)
 	if err != nil {
-		logger.Fatal(err.Error())
+		return "", "", err
+	}
+	if version == APIVersion2 {
+		return client.verifyFactorV2(url, requestBody, apiToken)
 	}
-	auth := "bearer:" + apiToken
 
 	mfaResponse := VerifyMFAResponse{}
-	httpRequest(url, auth, requestBody, &mfaResponse)
-
-	var samlData string
-	var samlErr error
-
-	if mfaResponse.Status.Code == 200 {
-		samlData = mfaResponse.Data
-	} else {
-		samlErr = errors.New(mfaResponse.Status.Message)
+	if err := client.httpRequest(url, "bearer:"+apiToken, requestBody, &mfaResponse); err != nil {
+		return "", "", err
+	}
+	if mfaResponse.Status.Code != 200 {
+		return "", "", verifyError(mfaResponse.Status.Code, mfaResponse.Status.Type, mfaResponse.Status.Message)
 	}
+	return mfaResponse.Data, mfaResponse.Status.Message, nil
+}
 
-	return samlData, samlErr
+// IsPushDevice test if an MFA device supports push notifications
+func IsPushDevice(device MFADevice) bool {
+	return strings.Contains(strings.ToLower(device.DeviceType), "onelogin protect")
 }
 
-// ParseSAMLAssertion parse the SAMLAssertion response data into a list of SAMLAssertionRoles
-func ParseSAMLAssertion(samlAssertion string, accountInfo Accounts, accountFilter []string,
-	role string) []*SAMLAssertionRole {
+// VerifyMFAPush Sends a push notification to the MFA device and polls
+// https://api.eu.onelogin.com/api/1/saml_assertion/verify_factor until it's approved, denied or
+// PushTimeout expires.
+func (client *Client) VerifyMFAPush(conf Config, deviceID int, samlAssertionData SAMLAssertionData,
+	apiToken string) (string, error) {
 
-	sDec, _ := b64.StdEncoding.DecodeString(samlAssertion)
+	version, err := client.apiVersion(conf)
+	if err != nil {
+		return "", err
+	}
+	url := samlAssertionData.CallbackURL
+	if url == "" {
+		url = verifyFactorURL(conf, version)
+	}
+	request := VerifyMFARequest{
+		AppID:      conf.AppID,
+		DeviceID:   strconv.Itoa(deviceID),
+		StateToken: samlAssertionData.StateToken}
+
+	interval := time.Duration(conf.PushPollInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Second
+	}
+	deadline := client.Clock.Now().Add(time.Duration(conf.PushTimeout) * time.Second)
+	for {
+		samlData, message, err := client.verifyFactor(version, url, request, apiToken)
+		if err != nil {
+			return "", err
+		}
+		if samlData != "" {
+			return samlData, nil
+		}
+		logger.Debug(message)
+
+		if client.Clock.Now().Add(interval).After(deadline) {
+			return "", ErrMFATimeout
+		}
+		client.Clock.Sleep(interval)
+		// Only the first request should trigger a push notification
+		request.DoNotNotify = true
+	}
+}
 
+// decodeSAMLResponse decodes the base64 encoded SAML response
+func decodeSAMLResponse(samlAssertion string) (Response, error) {
 	var samlResponse Response
+	sDec, err := b64.StdEncoding.DecodeString(samlAssertion)
+	if err != nil {
+		return samlResponse, fmt.Errorf("%w: %s", ErrInvalidAssertion, err)
+	}
 	if err := xml.Unmarshal(sDec, &samlResponse); err != nil {
-		logger.Fatal(err.Error())
+		return samlResponse, fmt.Errorf("%w: %s", ErrInvalidAssertion, err)
+	}
+	return samlResponse, nil
+}
+
+// ParseSAMLAssertion parse the SAMLAssertion response data into a list of SAMLAssertionRoles.
+// The role filter can be an alias of the role's account.
+// Malformed role values are skipped and reported by a *MalformedRoleError next to the valid roles.
+func ParseSAMLAssertion(samlAssertion string, accountInfo Accounts, accountFilter []string,
+	role string) ([]*SAMLAssertionRole, error) {
+
+	samlResponse, err := decodeSAMLResponse(samlAssertion)
+	if err != nil {
+		return nil, err
+	}
+	if samlResponse.Assertion == nil || samlResponse.Assertion.AttributeStatement == nil {
+		return nil, fmt.Errorf("%w: no attributes found", ErrInvalidAssertion)
 	}
 
 	attributes := samlResponse.Assertion.AttributeStatement.Attributes
+	sessionDuration := parseSAMLAttributes(attributes).SessionDuration
 
-	roles := []*SAMLAssertionRole{}
+	samlRoles := []*SAMLAssertionRole{}
+	malformed := &MalformedRoleError{}
 
 	for _, attribute := range attributes {
+		if attribute.Name != roleAttributeName {
+			continue
+		}
 		for _, value := range attribute.Values {
-			if strings.Contains(value.Value, "role") {
-
-				data := strings.Split(value.Value, ",")
-
-				assertionRole := SAMLAssertionRole{
-					RoleArn:      data[0],
-					PrincipalArn: data[1],
-					AccountID:    data[1][13:25],
-				}
-				assertionRole.AccountName, assertionRole.EnvironmentIndependent =
-					SearchAccounts(accountInfo, assertionRole.AccountID)
-
-				// Based on context, are we interested in this role?
-				if role == "" || strings.EqualFold(role, assertionRole.RoleArn[31:]) {
-					if accountFilter == nil {
-						roles = append(roles, &assertionRole)
-					} else if Contains(accountFilter, assertionRole.AccountID) {
-						roles = append(roles, &assertionRole)
-					}
-				}
+			assertionRole, err := parseRoleAttribute(value.Value)
+			if err != nil {
+				logger.Warn(err.Error())
+				malformed.add(value.Value, err)
+				continue
+			}
+			assertionRole.AccountName, assertionRole.EnvironmentIndependent =
+				SearchAccounts(accountInfo, assertionRole.AccountID)
+			assertionRole.SessionDuration = sessionDuration
+			samlRoles = append(samlRoles, &assertionRole)
+		}
+	}
+	// Chained roles can be reached even when their source role is filtered out
+	samlRoles = append(samlRoles, chainRoles(samlRoles, accountInfo)...)
+
+	roles := []*SAMLAssertionRole{}
+	for _, assertionRole := range samlRoles {
+		// Based on context, are we interested in this role?
+		if role == "" || strings.EqualFold(accountInfo.roleAlias(assertionRole.AccountID, role),
+			assertionRole.RoleName) {
+			if accountFilter == nil {
+				roles = append(roles, assertionRole)
+			} else if Contains(accountFilter, assertionRole.AccountID) {
+				roles = append(roles, assertionRole)
 			}
 		}
 	}
 	sort.Sort(RolesByName(roles))
-	return roles
+	if len(malformed.Values) > 0 {
+		return roles, malformed
+	}
+	return roles, nil
 }
 
-// AssumeRole assume a role on AWS
-func AssumeRole(samlAssertion string, duration int64, role *SAMLAssertionRole) *sts.AssumeRoleWithSAMLOutput {
+// SAMLAssertionExpiry returns the time after which the SAMLAssertion can no longer be used
+func SAMLAssertionExpiry(samlAssertion string) (time.Time, error) {
+	samlResponse, err := decodeSAMLResponse(samlAssertion)
+	if err != nil {
+		return time.Time{}, err
+	}
+	assertion := samlResponse.Assertion
+	if assertion == nil {
+		return time.Time{}, fmt.Errorf("%w: no assertion found", ErrInvalidAssertion)
+	}
 
-	session := session.Must(session.NewSession())
-	stsClient := sts.New(session)
+	// The earliest of the assertion's conditions and the subject's confirmation applies
+	var expiry time.Time
+	if assertion.Conditions != nil {
+		expiry = assertion.Conditions.NotOnOrAfter
+	}
+	if assertion.Subject != nil && assertion.Subject.SubjectConfirmation != nil {
+		notOnOrAfter := assertion.Subject.SubjectConfirmation.SubjectConfirmationData.NotOnOrAfter
+		if !notOnOrAfter.IsZero() && (expiry.IsZero() || notOnOrAfter.Before(expiry)) {
+			expiry = notOnOrAfter
+		}
+	}
+	return expiry, nil
+}
+
+// AssumeRole assume a role on AWS using the STS endpoint in the role's partition
+func (client *Client) AssumeRole(conf Config, samlAssertion string, duration int64,
+	role *SAMLAssertionRole) (*sts.AssumeRoleWithSAMLOutput, error) {
+
+	samlRole := role
+	if role.Source != nil {
+		samlRole = role.Source
+	}
+	stsClient, err := client.stsClient(conf, samlRole, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	input := sts.AssumeRoleWithSAMLInput{
 		DurationSeconds: &duration,
-		PrincipalArn:    &role.PrincipalArn,
-		RoleArn:         &role.RoleArn,
+		PrincipalArn:    &samlRole.PrincipalArn,
+		RoleArn:         &samlRole.RoleArn,
 		SAMLAssertion:   &samlAssertion}
 
 	output, err := stsClient.AssumeRoleWithSAML(&input)
+	for err != nil && isDurationError(err) && duration > minRetryDuration {
+		// Retry with the next full hour below the rejected duration
+		duration = (duration - 1) / minRetryDuration * minRetryDuration
+		if duration < minRetryDuration {
+			duration = minRetryDuration
+		}
+		logger.Sugar().Infof("Session duration rejected for role [%s], retrying with %d seconds.",
+			samlRole.RoleArn, duration)
+		output, err = stsClient.AssumeRoleWithSAML(&input)
+	}
 	if err != nil {
-		fmt.Println(err.Error())
-		logger.Fatal(err.Error())
+		return nil, fmt.Errorf("%w: %s", ErrSTSDenied, err)
+	}
+	if len(role.Chain) > 0 {
+		return client.assumeChain(conf, samlRole, role.Chain, duration, output)
 	}
-	return output
+	return output, nil
+}
+
+// minRetryDuration is the shortest session duration tried when STS rejects the requested duration
+const minRetryDuration = 3600
+
+// isDurationError reports whether STS rejected the requested session duration
+func isDurationError(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "ValidationError" &&
+		strings.Contains(awsErr.Message(), "DurationSeconds")
 }
 
 // SetCredentials Apply the STS credentials on the host
 func SetCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, homeDir string,
-	profileName string, legacyToken bool) {
+	profileName string, legacyToken bool) error {
 
 	var cfg *ini.File
 	ini.PrettyFormat = false
@@ -343,18 +619,18 @@ func SetCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, homeDir strin
 		filename = path + string(os.PathSeparator) + "credentials"
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			if err := os.Mkdir(path, 0755); err != nil {
-				logger.Fatal(err.Error())
+				return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 			}
 			logger.Info(".aws directory created.")
 		}
 		if _, err := os.Stat(filename); os.IsNotExist(err) {
 			emptyFile, err := os.Create(filename)
 			if err != nil {
-				logger.Fatal(err.Error())
+				return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 			}
 			emptyFile.Close()
 			if err := os.Chmod(filename, 0600); err != nil {
-				logger.Fatal(err.Error())
+				return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 			}
 			logger.Info("AWS credentials file created.")
 		}
@@ -363,31 +639,31 @@ func SetCredentials(assertionOutput *sts.AssumeRoleWithSAMLOutput, homeDir strin
 	var err error
 	cfg, err = ini.Load(filename)
 	if err != nil {
-		logger.Fatal(err.Error())
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 	}
 
 	sec := cfg.Section(profileName)
 	if _, err := sec.NewKey("aws_access_key_id", *assertionOutput.Credentials.AccessKeyId); err != nil {
-		logger.Fatal(err.Error())
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 	}
 	if _, err := sec.NewKey("aws_secret_access_key", *assertionOutput.Credentials.SecretAccessKey); err != nil {
-		logger.Fatal(err.Error())
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 	}
 	if _, err := sec.NewKey("aws_session_token", *assertionOutput.Credentials.SessionToken); err != nil {
-		logger.Fatal(err.Error())
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 	}
 	if legacyToken {
 		if _, err := sec.NewKey("aws_security_token", *assertionOutput.Credentials.SessionToken); err != nil {
-			logger.Fatal(err.Error())
+			return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 		}
 	} else {
 		sec.DeleteKey("aws_security_token")
 	}
-	err = cfg.SaveTo(filename)
-	logger.Sugar().Infof("AWS credentials saved to file for profile [%s].", profileName)
-	if err != nil {
-		logger.Fatal(err.Error())
+	if err := cfg.SaveTo(filename); err != nil {
+		return fmt.Errorf("%w: %s", ErrCredentialsFile, err)
 	}
+	logger.Sugar().Infof("AWS credentials saved to file for profile [%s].", profileName)
+	return nil
 }
 
 // Contains test if an array contains a string
@@ -400,42 +676,37 @@ func Contains(anArray []string, aString string) bool {
 	return false
 }
 
-func httpRequest(url string, auth string, jsonStr []byte, target interface{}) {
+func (client *Client) httpRequest(url string, auth string, jsonStr []byte, target interface{}) error {
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+	body, err := client.httpRequestRaw(url, auth, jsonStr)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	req.Header.Set("Authorization", auth)
-	req.Header.Set("Content-Type", "application/json")
-	logRequest(req)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		logger.Fatal(err.Error())
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
 	}
-	defer resp.Body.Close()
-
-	logResponse(resp)
+	return nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
-		logger.Fatal(err.Error())
-	}
+func (client *Client) httpRequestRaw(url string, auth string, jsonStr []byte) ([]byte, error) {
+	_, body, err := client.httpResponse(url, auth, jsonStr)
+	return body, err
 }
 
-func httpRequestRaw(url string, auth string, jsonStr []byte) []byte {
+// httpResponse posts the JSON request and returns the HTTP status code and the body of the response
+func (client *Client) httpResponse(url string, auth string, jsonStr []byte) (int, []byte, error) {
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
 	if err != nil {
-		panic(err)
+		return 0, nil, err
 	}
 	req.Header.Set("Authorization", auth)
 	req.Header.Set("Content-Type", "application/json")
 	logRequest(req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
-		logger.Fatal(err.Error())
+		return 0, nil, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
 	}
 	defer resp.Body.Close()
 
@@ -443,7 +714,7 @@ func httpRequestRaw(url string, auth string, jsonStr []byte) []byte {
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logger.Fatal(err.Error())
+		return 0, nil, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
 	}
-	return body
+	return resp.StatusCode, body, nil
 }
@@ -0,0 +1,167 @@
+package masl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+// maxDurationSTS rejects sessions longer than the role's maximum session duration
+type maxDurationSTS struct {
+	fakeSTS
+	maxDuration int64
+	durations   []int64
+}
+
+func (fake *maxDurationSTS) AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+	fake.durations = append(fake.durations, *input.DurationSeconds)
+	if *input.DurationSeconds > fake.maxDuration {
+		return nil, awserr.New("ValidationError",
+			"The requested DurationSeconds exceeds the MaxSessionDuration set for this role.", nil)
+	}
+	return testOutput("ASSUMED", time.Now().Add(time.Duration(*input.DurationSeconds)*time.Second)), nil
+}
+
+func TestAssumeRoleDurationRetry(t *testing.T) {
+	role := &SAMLAssertionRole{RoleArn: "arn:aws:iam::123456789012:role/admin"}
+	stsClient := &maxDurationSTS{maxDuration: 7200}
+	client := NewClient()
+	client.NewSTS = staticSTS(stsClient)
+
+	output, err := client.AssumeRole(Config{}, "assertion", 12*3600, role)
+	assert.NoError(t, err)
+	assert.Equal(t, "ASSUMED", *output.Credentials.AccessKeyId)
+	assert.Equal(t, []int64{43200, 39600, 36000, 32400, 28800, 25200, 21600, 18000, 14400, 10800, 7200},
+		stsClient.durations)
+
+	stsClient = &maxDurationSTS{maxDuration: 1800}
+	client.NewSTS = staticSTS(stsClient)
+	_, err = client.AssumeRole(Config{}, "assertion", 5400, role)
+	assert.True(t, errors.Is(err, ErrSTSDenied))
+	assert.Equal(t, []int64{5400, 3600}, stsClient.durations)
+}
+
+func TestSessionDuration(t *testing.T) {
+	conf := Config{Duration: 3600, Accounts: Accounts{{ID: "123456789012", Duration: 43200}}}
+	assert.Equal(t, int64(43200), SessionDuration(conf, &SAMLAssertionRole{AccountID: "123456789012"}))
+	assert.Equal(t, int64(3600), SessionDuration(conf, &SAMLAssertionRole{AccountID: "210987654321"}))
+}
+
+func TestOneLoginStatusErrors(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		err     error
+	}{
+		{401, "Authentication Failed: Invalid user credentials", ErrInvalidCredentials},
+		{401, "Authentication Failed: Account is locked", ErrAccountLocked},
+		{401, "Authentication Failure", ErrAPIToken},
+		{400, "Bad Request", ErrOneLoginAPI},
+		// The status code decides when the message is reworded
+		{401, "Login failed", ErrInvalidCredentials},
+		{423, "Too many failed attempts", ErrAccountLocked},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := statusResponse{}
+			response.Status.Code = test.code
+			response.Status.Message = test.message
+			writeJSON(w, response)
+		}))
+		_, err := NewClient().SAMLAssertion(Config{BaseURL: server.URL + "/"}, "password", "token")
+		assert.ErrorIs(t, err, test.err, test.message)
+		server.Close()
+	}
+}
+
+func TestVerifyMFAStatusErrors(t *testing.T) {
+	message := "Failed authentication with this factor"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := VerifyMFAResponse{}
+		response.Status.Code = 401
+		response.Status.Message = message
+		writeJSON(w, response)
+	}))
+	defer server.Close()
+
+	conf := Config{BaseURL: server.URL + "/"}
+	_, err := NewClient().VerifyMFA(conf, 1, "state", "123456", "token")
+	assert.ErrorIs(t, err, ErrMFARejected)
+
+	message = "Invalid state_token"
+	_, err = NewClient().VerifyMFA(conf, 1, "state", "123456", "token")
+	assert.ErrorIs(t, err, ErrStateTokenExpired)
+}
+
+func TestVerifyError(t *testing.T) {
+	assert.ErrorIs(t, verifyError(404, "Not Found", "Not found"), ErrStateTokenExpired)
+	assert.ErrorIs(t, verifyError(410, "", "Gone"), ErrStateTokenExpired)
+	assert.ErrorIs(t, verifyError(423, "", "Try again later"), ErrAccountLocked)
+	assert.ErrorIs(t, verifyError(400, "bad request", "Invalid state_token"), ErrStateTokenExpired)
+	assert.ErrorIs(t, verifyError(401, "Unauthorized", "Failed authentication with this factor"), ErrMFARejected)
+}
+
+// steppingClock advances the time on every Sleep
+type steppingClock struct {
+	now time.Time
+}
+
+func (clock *steppingClock) Now() time.Time        { return clock.now }
+func (clock *steppingClock) Sleep(d time.Duration) { clock.now = clock.now.Add(d) }
+
+func TestVerifyMFAPush(t *testing.T) {
+	var requests []VerifyMFARequest
+	approveAfter := 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := VerifyMFARequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		response := VerifyMFAResponse{}
+		response.Status.Code = 200
+		switch {
+		case approveAfter < 0:
+			response.Status.Code = 401
+			response.Status.Message = "Authentication pending denied"
+		case len(requests) >= approveAfter:
+			response.Data = "assertion"
+		default:
+			response.Status.Message = "Authentication pending on OL Protect"
+		}
+		writeJSON(w, response)
+	}))
+	defer server.Close()
+
+	conf := Config{BaseURL: server.URL + "/", AppID: "app", PushPollInterval: 2, PushTimeout: 60}
+	data := SAMLAssertionData{StateToken: "state"}
+	client := NewClient()
+	client.Clock = &steppingClock{now: time.Now()}
+
+	samlData, err := client.VerifyMFAPush(conf, 1, data, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "assertion", samlData)
+	assert.Len(t, requests, 3)
+	// Only the first request sends the push notification
+	assert.False(t, requests[0].DoNotNotify)
+	assert.True(t, requests[1].DoNotNotify)
+	assert.Equal(t, "state", requests[2].StateToken)
+
+	requests = nil
+	approveAfter = 1000
+	_, err = client.VerifyMFAPush(conf, 1, data, "token")
+	assert.ErrorIs(t, err, ErrMFATimeout)
+	assert.Len(t, requests, 31)
+
+	requests = nil
+	approveAfter = -1
+	_, err = client.VerifyMFAPush(conf, 1, data, "token")
+	assert.ErrorIs(t, err, ErrMFARejected)
+	assert.Len(t, requests, 1)
+}
@@ -0,0 +1,162 @@
+package masl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+/* #nosec */
+const (
+	samlAssertionV2API = "api/2/saml_assertion"
+	verifyFactorV2API  = "api/2/saml_assertion/verify_factor"
+)
+
+// The OneLogin API versions, with APIVersionAuto v1 is used until OneLogin reports it's no longer
+// available
+const (
+	APIVersionAuto = "auto"
+	APIVersion1    = "1"
+	APIVersion2    = "2"
+)
+
+// samlAssertionResponseV2 represents the OneLogin API v2 SAML Assertion response, the state token and
+// devices are only set when MFA is required
+type samlAssertionResponseV2 struct {
+	Message     string      `json:"message"`
+	Data        string      `json:"data"`
+	StateToken  string      `json:"state_token"`
+	CallbackURL string      `json:"callback_url"`
+	Devices     []MFADevice `json:"devices"`
+}
+
+// verifyMFAResponseV2 represents the OneLogin API v2 Verify MFA response
+type verifyMFAResponseV2 struct {
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+// errorResponseV2 represents the body of a failed OneLogin API v2 request
+type errorResponseV2 struct {
+	StatusCode int    `json:"statusCode"`
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+}
+
+// apiUnavailableError reports that the OneLogin API endpoint doesn't exist (anymore)
+type apiUnavailableError struct {
+	url        string
+	statusCode int
+}
+
+func (err *apiUnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s returned %d %s", ErrOneLoginAPI, err.url, err.statusCode,
+		http.StatusText(err.statusCode))
+}
+
+func (err *apiUnavailableError) Unwrap() error {
+	return ErrOneLoginAPI
+}
+
+// detectAPIVersion test if the API version is detected automatically
+func detectAPIVersion(conf Config) bool {
+	return conf.APIVersion == "" || conf.APIVersion == APIVersionAuto
+}
+
+// apiVersion returns the OneLogin API version to use, a detected version is remembered for the API URL
+func (client *Client) apiVersion(conf Config) (string, error) {
+	switch {
+	case conf.APIVersion == APIVersion1 || conf.APIVersion == APIVersion2:
+		return conf.APIVersion, nil
+	case !detectAPIVersion(conf):
+		return "", fmt.Errorf("%w: unknown APIVersion [%s]", ErrInvalidConfig, conf.APIVersion)
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if version, ok := client.apiVersions[conf.BaseURL]; ok {
+		return version, nil
+	}
+	return APIVersion1, nil
+}
+
+func (client *Client) setAPIVersion(conf Config, version string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.apiVersions == nil {
+		client.apiVersions = map[string]string{}
+	}
+	client.apiVersions[conf.BaseURL] = version
+}
+
+// verifyFactorURL returns the verify_factor endpoint of the API version
+func verifyFactorURL(conf Config, version string) string {
+	if version == APIVersion2 {
+		return conf.BaseURL + verifyFactorV2API
+	}
+	return conf.BaseURL + verifyFactorAPI
+}
+
+// v2Error returns the error for a failed OneLogin API v2 request, classified by the status code, the
+// name and the message taken from the body when available
+func v2Error(statusCode int, body []byte, classify func(code int, statusType string, message string) error) error {
+	errorResponse := errorResponseV2{}
+	if json.Unmarshal(body, &errorResponse) != nil || errorResponse.Message == "" {
+		errorResponse.Message = fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode))
+	}
+	logger.Info(errorResponse.Message)
+	return classify(statusCode, errorResponse.Name, errorResponse.Message)
+}
+
+// samlAssertionV2 Call to https://api.eu.onelogin.com/api/2/saml_assertion
+func (client *Client) samlAssertionV2(conf Config, requestBody []byte, apiToken string) (SAMLAssertionData, error) {
+
+	statusCode, body, err := client.httpResponse(conf.BaseURL+samlAssertionV2API, "Bearer "+apiToken, requestBody)
+	if err != nil {
+		return SAMLAssertionData{}, err
+	}
+	if statusCode != http.StatusOK {
+		return SAMLAssertionData{}, v2Error(statusCode, body, assertionError)
+	}
+
+	assertionResponse := samlAssertionResponseV2{}
+	if err := json.Unmarshal(body, &assertionResponse); err != nil {
+		return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
+	}
+	logger.Info(assertionResponse.Message)
+
+	switch {
+	case assertionResponse.StateToken != "":
+		// MFA token is required
+		return SAMLAssertionData{
+			MFARequired: true,
+			StateToken:  assertionResponse.StateToken,
+			CallbackURL: assertionResponse.CallbackURL,
+			Devices:     assertionResponse.Devices,
+		}, nil
+	case assertionResponse.Data != "":
+		logger.Info("MFA not required")
+		return SAMLAssertionData{
+			MFARequired: false,
+			Data:        assertionResponse.Data,
+		}, nil
+	default:
+		return SAMLAssertionData{}, fmt.Errorf("%w: %s", ErrOneLoginAPI, assertionResponse.Message)
+	}
+}
+
+// verifyFactorV2 Call to https://api.eu.onelogin.com/api/2/saml_assertion/verify_factor
+func (client *Client) verifyFactorV2(url string, requestBody []byte, apiToken string) (string, string, error) {
+
+	statusCode, body, err := client.httpResponse(url, "Bearer "+apiToken, requestBody)
+	if err != nil {
+		return "", "", err
+	}
+	if statusCode != http.StatusOK {
+		return "", "", v2Error(statusCode, body, verifyError)
+	}
+	mfaResponse := verifyMFAResponseV2{}
+	if err := json.Unmarshal(body, &mfaResponse); err != nil {
+		return "", "", fmt.Errorf("%w: %s", ErrOneLoginAPI, err)
+	}
+	return mfaResponse.Data, mfaResponse.Message, nil
+}
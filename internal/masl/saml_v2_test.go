@@ -0,0 +1,88 @@
+package masl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newOneLoginV2 serves the OneLogin API v2 SAML assertion endpoints, v1 isn't available
+func newOneLoginV2(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/1/saml_assertion", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/api/2/saml_assertion", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		request := SAMLAssertionRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.Password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"statusCode":401,"name":"Unauthorized",
+				"message":"Authentication Failed: Invalid user credentials"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"state_token":"state","message":"MFA is required for this user",
+			"devices":[{"device_id":1,"device_type":"Yubico YubiKey"}]}`))
+	})
+	mux.HandleFunc("/api/2/saml_assertion/verify_factor", func(w http.ResponseWriter, r *http.Request) {
+		request := VerifyMFARequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "state", request.StateToken)
+		if request.OtpToken != "123456" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"statusCode":401,"name":"Unauthorized",
+				"message":"Failed authentication with this factor"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":"assertion","message":"Success"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSAMLAssertionV2(t *testing.T) {
+	server := newOneLoginV2(t)
+	defer server.Close()
+
+	conf := Config{BaseURL: server.URL + "/", APIVersion: APIVersion2}
+	client := NewClient()
+	samlAssertionData, err := client.SAMLAssertion(conf, "password", "token")
+	assert.NoError(t, err)
+	assert.True(t, samlAssertionData.MFARequired)
+	assert.Equal(t, "state", samlAssertionData.StateToken)
+	assert.Equal(t, []MFADevice{{DeviceID: 1, DeviceType: "Yubico YubiKey"}}, samlAssertionData.Devices)
+
+	_, err = client.SAMLAssertion(conf, "wrong", "token")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	samlData, err := client.VerifyMFA(conf, 1, "state", "123456", "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "assertion", samlData)
+	_, err = client.VerifyMFA(conf, 1, "state", "000000", "token")
+	assert.ErrorIs(t, err, ErrMFARejected)
+}
+
+func TestAPIVersionDetection(t *testing.T) {
+	server := newOneLoginV2(t)
+	defer server.Close()
+
+	_, err := NewClient().SAMLAssertion(Config{BaseURL: server.URL + "/", APIVersion: APIVersion1},
+		"password", "token")
+	assert.ErrorIs(t, err, ErrOneLoginAPI)
+
+	// v2 is used once v1 turns out to be unavailable
+	conf := Config{BaseURL: server.URL + "/"}
+	client := NewClient()
+	samlAssertionData, err := client.SAMLAssertion(conf, "password", "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "state", samlAssertionData.StateToken)
+	samlData, err := client.VerifyMFA(conf, 1, "state", "123456", "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "assertion", samlData)
+
+	_, err = client.SAMLAssertion(Config{BaseURL: server.URL + "/", APIVersion: "3"}, "password", "token")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -0,0 +1,117 @@
+package masl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The secrets masl can keep in a SecretStore
+const (
+	SecretClientSecret = "client-secret"
+	SecretPassword     = "password"
+)
+
+// The secret stores, SecretStoreAuto picks the OS keyring when available and the encrypted file otherwise
+const (
+	SecretStoreAuto          = "auto"
+	SecretStoreSecretService = "secret-service"
+	SecretStoreKWallet       = "kwallet"
+	SecretStoreFile          = "file"
+)
+
+// secretService identifies the secrets of masl in the OS keyring
+const secretService = "masl"
+
+// SecretStore represents a place to keep the secrets of masl
+type SecretStore interface {
+	// Get returns the secret, ErrSecretNotFound is returned for unknown secrets
+	Get(name string) (string, error)
+	Set(name string, value string) error
+	Delete(name string) error
+}
+
+// NewSecretStore returns the SecretStore configured by SecretStore. The passphrase function is
+// called when the encrypted file needs to be unlocked.
+func NewSecretStore(conf Config, homeDir string, passphrase func() (string, error)) (SecretStore, error) {
+	switch conf.SecretStore {
+	case "", SecretStoreAuto:
+		if store := keyringStore(); store != nil {
+			return store, nil
+		}
+		return newFileStore(homeDir, passphrase), nil
+	case SecretStoreSecretService, SecretStoreKWallet:
+		store := keyringStoreFor(conf.SecretStore)
+		if store == nil {
+			return nil, fmt.Errorf("%w: secret store [%s] is not available", ErrInvalidConfig, conf.SecretStore)
+		}
+		return store, nil
+	case SecretStoreFile:
+		return newFileStore(homeDir, passphrase), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown secret store [%s]", ErrInvalidConfig, conf.SecretStore)
+	}
+}
+
+// SecretCommand runs a command printing a secret, like `pass show onelogin`, and returns the first
+// line of its output
+func SecretCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret command [%s] failed: %s %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(strings.SplitN(string(output), "\n", 2)[0], "\r"), nil
+}
+
+// ClientSecret returns the OneLogin client secret from the config, the ClientSecretCommand or the
+// secret store
+func ClientSecret(conf Config, store func() (SecretStore, error)) (string, error) {
+	if conf.ClientSecret != "" {
+		return conf.ClientSecret, nil
+	}
+	if conf.ClientSecretCommand != "" {
+		return SecretCommand(conf.ClientSecretCommand)
+	}
+	secretStore, err := store()
+	if err != nil {
+		return "", err
+	}
+	secret, err := secretStore.Get(SecretClientSecret)
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", fmt.Errorf("%w: no ClientSecret configured: %s", ErrInvalidConfig, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Password returns the OneLogin password from the PasswordCommand or the secret store. An empty
+// password is returned when neither has it, the user has to be asked for it.
+func Password(conf Config, store func() (SecretStore, error)) (string, error) {
+	if conf.PasswordCommand != "" {
+		return SecretCommand(conf.PasswordCommand)
+	}
+	secretStore, err := store()
+	if err != nil {
+		return "", err
+	}
+	password, err := secretStore.Get(SecretPassword)
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", nil
+	}
+	return password, err
+}
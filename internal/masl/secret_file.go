@@ -0,0 +1,153 @@
+package masl
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const secretsFileName = "secrets.json"
+
+// pbkdf2Iterations is the number of PBKDF2 iterations deriving the key from the passphrase
+const pbkdf2Iterations = 200000
+
+// secretsFile represents the encrypted secrets file, every secret is sealed with AES-GCM using a
+// key derived from the passphrase
+type secretsFile struct {
+	Salt    []byte            `json:"salt"`
+	Secrets map[string][]byte `json:"secrets"`
+}
+
+// fileStore keeps the secrets in an encrypted file in the .masl directory
+type fileStore struct {
+	homeDir    string
+	filename   string
+	passphrase func() (string, error)
+	key        []byte
+}
+
+func newFileStore(homeDir string, passphrase func() (string, error)) *fileStore {
+	return &fileStore{
+		homeDir:    homeDir,
+		filename:   maslPath(homeDir, secretsFileName),
+		passphrase: passphrase,
+	}
+}
+
+func (store *fileStore) read() (secretsFile, error) {
+	secrets := secretsFile{Secrets: map[string][]byte{}}
+	data, err := ioutil.ReadFile(store.filename)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return secrets, err
+	}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return secrets, fmt.Errorf("invalid secrets file %s: %s", store.filename, err)
+	}
+	if secrets.Secrets == nil {
+		secrets.Secrets = map[string][]byte{}
+	}
+	return secrets, nil
+}
+
+func (store *fileStore) write(secrets secretsFile) error {
+	return writeJSONFile(store.homeDir, secretsFileName, secrets)
+}
+
+// aead unlocks the secrets with the passphrase, it's only asked once
+func (store *fileStore) aead(salt []byte) (cipher.AEAD, error) {
+	if store.key == nil {
+		passphrase, err := store.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		store.key = pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, 32, sha256.New)
+	}
+	block, err := aes.NewCipher(store.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (store *fileStore) Get(name string) (string, error) {
+	secrets, err := store.read()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := secrets.Secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	aead, err := store.aead(secrets.Salt)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid secret [%s] in %s", name, store.filename)
+	}
+	// The name is authenticated, a secret can't be swapped for another one
+	value, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+	if err != nil {
+		store.key = nil
+		return "", fmt.Errorf("unable to decrypt secret [%s], wrong passphrase?", name)
+	}
+	return string(value), nil
+}
+
+func (store *fileStore) Set(name string, value string) error {
+	secrets, err := store.read()
+	if err != nil {
+		return err
+	}
+	if len(secrets.Salt) == 0 {
+		secrets.Salt = make([]byte, 16)
+		if _, err := rand.Read(secrets.Salt); err != nil {
+			return err
+		}
+	}
+	aead, err := store.aead(secrets.Salt)
+	if err != nil {
+		return err
+	}
+	// Verify the passphrase on an existing secret, all secrets share the same key
+	for existing, sealed := range secrets.Secrets {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		if _, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():],
+			[]byte(existing)); err != nil {
+			store.key = nil
+			return fmt.Errorf("unable to decrypt the secrets file, wrong passphrase?")
+		}
+		break
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	secrets.Secrets[name] = aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return store.write(secrets)
+}
+
+func (store *fileStore) Delete(name string) error {
+	secrets, err := store.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets.Secrets[name]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(secrets.Secrets, name)
+	return store.write(secrets)
+}
@@ -0,0 +1,122 @@
+package masl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keyringStore returns the available OS keyring, if any
+func keyringStore() SecretStore {
+	for _, backend := range []string{SecretStoreSecretService, SecretStoreKWallet} {
+		if store := keyringStoreFor(backend); store != nil {
+			return store
+		}
+	}
+	return nil
+}
+
+// keyringStoreFor returns the keyring backend when its command line tool and a session are available
+func keyringStoreFor(backend string) SecretStore {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	switch backend {
+	case SecretStoreSecretService:
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return secretServiceStore{}
+		}
+	case SecretStoreKWallet:
+		if _, err := exec.LookPath("kwallet-query"); err == nil {
+			return kwalletStore{wallet: "kdewallet"}
+		}
+	}
+	return nil
+}
+
+// runKeyring runs a keyring command line tool, passing input on stdin. The standard error output is
+// returned next to a failure.
+func runKeyring(input string, name string, args ...string) (string, string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		return "", message, fmt.Errorf("%s failed: %w %s", name, err, message)
+	}
+	return stdout.String(), "", nil
+}
+
+// secretServiceStore keeps the secrets in the Secret Service (GNOME Keyring, KeePassXC, ...)
+// using secret-tool
+type secretServiceStore struct{}
+
+func (secretServiceStore) Get(name string) (string, error) {
+	output, stderr, err := runKeyring("", "secret-tool", "lookup", "service", secretService, "key", name)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && stderr == "" {
+		// secret-tool exits with 1 without output for unknown secrets, a locked keyring or an
+		// unreachable Secret Service is reported on stderr
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if output == "" {
+		return "", ErrSecretNotFound
+	}
+	return output, nil
+}
+
+func (secretServiceStore) Set(name string, value string) error {
+	_, _, err := runKeyring(value, "secret-tool", "store", "--label", secretService+" "+name,
+		"service", secretService, "key", name)
+	return err
+}
+
+func (secretServiceStore) Delete(name string) error {
+	_, _, err := runKeyring("", "secret-tool", "clear", "service", secretService, "key", name)
+	return err
+}
+
+// kwalletStore keeps the secrets in a folder of a KDE wallet using kwallet-query
+type kwalletStore struct {
+	wallet string
+}
+
+func (store kwalletStore) Get(name string) (string, error) {
+	output, stderr, err := runKeyring("", "kwallet-query", "-f", secretService, "-r", name, store.wallet)
+	if err != nil && kwalletNotFound(stderr) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	output = strings.TrimSuffix(output, "\n")
+	if output == "" {
+		return "", ErrSecretNotFound
+	}
+	return output, nil
+}
+
+// kwalletNotFound test if kwallet-query failed because the folder or entry doesn't exist, it has no
+// distinct exit code for it
+func kwalletNotFound(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	return strings.Contains(stderr, "does not exist") || strings.Contains(stderr, "failed to read entry")
+}
+
+func (store kwalletStore) Set(name string, value string) error {
+	_, _, err := runKeyring(value, "kwallet-query", "-f", secretService, "-w", name, store.wallet)
+	return err
+}
+
+// Delete empties the secret, kwallet-query can't remove entries
+func (store kwalletStore) Delete(name string) error {
+	return store.Set(name, "")
+}
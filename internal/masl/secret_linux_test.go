@@ -0,0 +1,50 @@
+package masl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyringTool installs a keyring command line tool running script in the PATH
+func fakeKeyringTool(t *testing.T, name string, script string) func() {
+	dir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0700))
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSecretServiceStoreErrors(t *testing.T) {
+	restore := fakeKeyringTool(t, "secret-tool", "exit 1\n")
+	_, err := secretServiceStore{}.Get(SecretPassword)
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+	restore()
+
+	restore = fakeKeyringTool(t, "secret-tool", "echo 'Cannot create an item in a locked collection' >&2\nexit 1\n")
+	defer restore()
+	_, err = secretServiceStore{}.Get(SecretPassword)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrSecretNotFound)
+	assert.Contains(t, err.Error(), "locked collection")
+}
+
+func TestKWalletStoreErrors(t *testing.T) {
+	restore := fakeKeyringTool(t, "kwallet-query", "echo 'The folder masl does not exist!' >&2\nexit 1\n")
+	_, err := kwalletStore{wallet: "kdewallet"}.Get(SecretPassword)
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+	restore()
+
+	restore = fakeKeyringTool(t, "kwallet-query", "echo 'Wallet kdewallet not found' >&2\nexit 1\n")
+	defer restore()
+	_, err = kwalletStore{wallet: "kdewallet"}.Get(SecretPassword)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrSecretNotFound)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package masl
+
+// keyringStore returns the available OS keyring, only the Linux keyrings are supported
+func keyringStore() SecretStore {
+	return nil
+}
+
+// keyringStoreFor returns the keyring backend, only the Linux keyrings are supported
+func keyringStoreFor(backend string) SecretStore {
+	return nil
+}
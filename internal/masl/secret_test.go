@@ -0,0 +1,81 @@
+package masl
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".masl"), 0700))
+
+	passphrase := "correct horse"
+	prompts := 0
+	newStore := func() SecretStore {
+		store, err := NewSecretStore(Config{SecretStore: SecretStoreFile}, homeDir, func() (string, error) {
+			prompts++
+			return passphrase, nil
+		})
+		assert.NoError(t, err)
+		return store
+	}
+
+	store := newStore()
+	_, err = store.Get(SecretPassword)
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+	assert.Equal(t, 0, prompts, "no passphrase is needed for unknown secrets")
+
+	assert.NoError(t, store.Set(SecretPassword, "hunter2"))
+	assert.NoError(t, store.Set(SecretClientSecret, "s3cret"))
+	assert.Equal(t, 1, prompts)
+
+	data, err := ioutil.ReadFile(filepath.Join(homeDir, ".masl", secretsFileName))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	store = newStore()
+	password, err := store.Get(SecretPassword)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+
+	passphrase = "wrong"
+	store = newStore()
+	_, err = store.Get(SecretClientSecret)
+	assert.Error(t, err)
+	assert.Error(t, store.Set(SecretPassword, "other"))
+
+	passphrase = "correct horse"
+	store = newStore()
+	assert.NoError(t, store.Delete(SecretPassword))
+	password, err = Password(Config{}, func() (SecretStore, error) { return store, nil })
+	assert.NoError(t, err)
+	assert.Empty(t, password)
+}
+
+func TestClientSecret(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the secret command uses sh")
+	}
+	noStore := func() (SecretStore, error) { return nil, errors.New("no secret store") }
+
+	secret, err := ClientSecret(Config{ClientSecret: "plain", ClientSecretCommand: "echo command"}, noStore)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", secret)
+
+	secret, err = ClientSecret(Config{ClientSecretCommand: "printf 'command\nsecond line'"}, noStore)
+	assert.NoError(t, err)
+	assert.Equal(t, "command", secret)
+
+	_, err = ClientSecret(Config{ClientSecretCommand: "exit 1"}, noStore)
+	assert.Error(t, err)
+	_, err = ClientSecret(Config{}, noStore)
+	assert.Error(t, err)
+}
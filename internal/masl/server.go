@@ -0,0 +1,61 @@
+package masl
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ECSCredentialsPath is the path the container credentials are served on
+const ECSCredentialsPath = "/credentials"
+
+// ecsCredentials represents the ECS container credentials response
+//
+// See https://docs.aws.amazon.com/sdkref/latest/guide/feature-container-credentials.html
+type ecsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+	RoleArn         string `json:"RoleArn"`
+}
+
+// ECSHandler serves the credentials of the source following the ECS container credentials
+// protocol. Requests have to carry the token in their Authorization header.
+func ECSHandler(source *CredentialSource, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ECSCredentialsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(token)) != 1 {
+			logger.Warn("Rejected a credentials request with an invalid authorization token")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		output, err := source.Credentials()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, ecsCredentials{
+			AccessKeyID:     *output.Credentials.AccessKeyId,
+			SecretAccessKey: *output.Credentials.SecretAccessKey,
+			Token:           *output.Credentials.SessionToken,
+			Expiration:      output.Credentials.Expiration.UTC().Format(time.RFC3339),
+			RoleArn:         source.Role().RoleArn,
+		})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,154 @@
+package masl
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSTS struct {
+	calls  int
+	chains []*sts.AssumeRoleInput
+}
+
+func (fake *fakeSTS) AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+	fake.calls++
+	output := testOutput("REFRESHED", time.Now().Add(time.Hour))
+	output.AssumedRoleUser = &sts.AssumedRoleUser{Arn: aws.String(
+		strings.Replace(strings.Replace(*input.RoleArn, ":iam:", ":sts:", 1), ":role/", ":assumed-role/", 1) +
+			"/me@example.com")}
+	return output, nil
+}
+
+func (fake *fakeSTS) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	fake.chains = append(fake.chains, input)
+	return &sts.AssumeRoleOutput{Credentials: testOutput(*input.RoleArn, time.Now().Add(time.Hour)).Credentials}, nil
+}
+
+// staticSTS returns the same STS client for every role
+func staticSTS(stsClient STSAPI) STSFactory {
+	return func(*aws.Config) STSAPI { return stsClient }
+}
+
+func testOutput(accessKeyID string, expiration time.Time) *sts.AssumeRoleWithSAMLOutput {
+	return &sts.AssumeRoleWithSAMLOutput{Credentials: &sts.Credentials{
+		AccessKeyId:     aws.String(accessKeyID),
+		SecretAccessKey: aws.String("SECRET"),
+		SessionToken:    aws.String("TOKEN"),
+		Expiration:      aws.Time(expiration),
+	}}
+}
+
+func testAssertion(notOnOrAfter time.Time) string {
+	return b64.StdEncoding.EncodeToString([]byte(`<samlp:Response
+ xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+ <saml:Assertion><saml:Conditions NotOnOrAfter="` + notOnOrAfter.UTC().Format(time.RFC3339) + `"/></saml:Assertion>
+</samlp:Response>`))
+}
+
+func TestECSHandler(t *testing.T) {
+	role := &SAMLAssertionRole{RoleArn: "arn:aws:iam::123456789012:role/admin"}
+	stsClient := &fakeSTS{}
+	client := NewClient()
+	client.NewSTS = staticSTS(stsClient)
+
+	source := client.NewCredentialSource(Config{}, testAssertion(time.Now().Add(time.Minute)), 3600, role,
+		testOutput("CURRENT", time.Now().Add(time.Hour)), nil)
+	handler := ECSHandler(source, "token")
+
+	request := httptest.NewRequest(http.MethodGet, ECSCredentialsPath, nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	request.Header.Set("Authorization", "token")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	credentials := ecsCredentials{}
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&credentials))
+	assert.Equal(t, "CURRENT", credentials.AccessKeyID)
+	assert.Equal(t, role.RoleArn, credentials.RoleArn)
+	assert.Equal(t, 0, stsClient.calls)
+
+	// Credentials about to expire are refreshed with the SAML assertion
+	source.output = testOutput("CURRENT", time.Now().Add(time.Minute))
+	output, err := source.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "REFRESHED", *output.Credentials.AccessKeyId)
+	assert.Equal(t, 1, stsClient.calls)
+}
+
+func TestCredentialSourceExpiredAssertion(t *testing.T) {
+	client := NewClient()
+	client.NewSTS = staticSTS(&fakeSTS{})
+	logins := 0
+	login := func() (string, error) {
+		logins++
+		return testAssertion(time.Now().Add(time.Minute)), nil
+	}
+
+	source := client.NewCredentialSource(Config{}, testAssertion(time.Now().Add(-time.Minute)), 3600,
+		&SAMLAssertionRole{}, testOutput("CURRENT", time.Now().Add(time.Minute)), login)
+	output, err := source.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "REFRESHED", *output.Credentials.AccessKeyId)
+	assert.Equal(t, 1, logins)
+
+	// Without a way to log in the current credentials are used until they expire
+	source = client.NewCredentialSource(Config{}, "", 3600, &SAMLAssertionRole{},
+		testOutput("CURRENT", time.Now().Add(time.Minute)), nil)
+	output, err = source.Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "CURRENT", *output.Credentials.AccessKeyId)
+
+	source.output = testOutput("CURRENT", time.Now().Add(-time.Minute))
+	_, err = source.Credentials()
+	assert.Error(t, err)
+}
+
+func TestIMDSHandler(t *testing.T) {
+	role := &SAMLAssertionRole{RoleArn: "arn:aws:iam::123456789012:role/admin", RoleName: "admin",
+		AccountID: "123456789012", Partition: "aws", Path: "/"}
+	source := NewClient().NewCredentialSource(Config{}, "", 3600, role,
+		testOutput("CURRENT", time.Now().Add(time.Hour)), nil)
+	server := httptest.NewServer(IMDSHandler(source, "eu-west-1"))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().WithRegion("eu-west-1")))
+	metadata := ec2metadata.New(sess, aws.NewConfig().WithEndpoint(server.URL))
+
+	credentials, err := ec2rolecreds.NewCredentialsWithClient(metadata).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "CURRENT", credentials.AccessKeyID)
+	assert.Equal(t, "TOKEN", credentials.SessionToken)
+
+	region, err := metadata.Region()
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+
+	document, err := metadata.GetInstanceIdentityDocument()
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012", document.AccountID)
+
+	info, err := metadata.IAMInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/admin", info.InstanceProfileArn)
+
+	// Metadata requires a session token
+	response, err := http.Get(server.URL + imdsSecurityCredentials)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
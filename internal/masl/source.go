@@ -0,0 +1,94 @@
+package masl
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// refreshWindow is the remaining lifetime at which credentials get refreshed. It exceeds the
+// windows used by the AWS SDKs so they never receive credentials they consider stale.
+const refreshWindow = 15 * time.Minute
+
+// CredentialSource hands out the credentials of a role and assumes the role again before they
+// expire, for as long as the SAML assertion is valid. Afterwards Login is asked for a new SAML
+// assertion.
+type CredentialSource struct {
+	client        *Client
+	conf          Config
+	role          *SAMLAssertionRole
+	duration      int64
+	samlAssertion string
+	login         func() (string, error)
+
+	mutex  sync.Mutex
+	output *sts.AssumeRoleWithSAMLOutput
+}
+
+// NewCredentialSource creates a CredentialSource for a role, starting from the current
+// credentials. The SAML assertion is optional, login is called when it's missing or expired.
+func (client *Client) NewCredentialSource(conf Config, samlAssertion string, duration int64,
+	role *SAMLAssertionRole, assertionOutput *sts.AssumeRoleWithSAMLOutput,
+	login func() (string, error)) *CredentialSource {
+
+	return &CredentialSource{
+		client:        client,
+		conf:          conf,
+		role:          role,
+		duration:      duration,
+		samlAssertion: samlAssertion,
+		login:         login,
+		output:        assertionOutput,
+	}
+}
+
+// NewCredentialSource creates a CredentialSource using the DefaultClient
+func NewCredentialSource(conf Config, samlAssertion string, duration int64, role *SAMLAssertionRole,
+	assertionOutput *sts.AssumeRoleWithSAMLOutput, login func() (string, error)) *CredentialSource {
+	return DefaultClient.NewCredentialSource(conf, samlAssertion, duration, role, assertionOutput, login)
+}
+
+// Role returns the role the credentials belong to
+func (source *CredentialSource) Role() *SAMLAssertionRole {
+	return source.role
+}
+
+// Credentials returns valid credentials, refreshing them when they're about to expire
+func (source *CredentialSource) Credentials() (*sts.AssumeRoleWithSAMLOutput, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	now := source.client.Clock.Now()
+	if source.output != nil && source.output.Credentials.Expiration.After(now.Add(refreshWindow)) {
+		return source.output, nil
+	}
+
+	output, err := source.refresh(now)
+	if err != nil {
+		// Keep handing out the current credentials while they're still valid
+		if source.output != nil && source.output.Credentials.Expiration.After(now) {
+			logger.Warn(err.Error())
+			return source.output, nil
+		}
+		return nil, err
+	}
+	source.output = output
+	return output, nil
+}
+
+func (source *CredentialSource) refresh(now time.Time) (*sts.AssumeRoleWithSAMLOutput, error) {
+	if expiry, err := SAMLAssertionExpiry(source.samlAssertion); err != nil || !expiry.After(now) {
+		if source.login == nil {
+			return nil, errors.New("the SAML assertion expired, a new login is required")
+		}
+		samlAssertion, err := source.login()
+		if err != nil {
+			return nil, err
+		}
+		source.samlAssertion = samlAssertion
+	}
+	logger.Sugar().Infof("Refreshing the credentials for role [%s].", source.role.RoleArn)
+	return source.client.AssumeRole(source.conf, source.samlAssertion, source.duration, source.role)
+}
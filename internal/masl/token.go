@@ -0,0 +1,121 @@
+package masl
+
+import (
+	"errors"
+	"time"
+)
+
+const tokenCacheFileName = "token.json"
+
+// tokenMinLifetime is the minimum remaining lifetime of a cached API token to be reused
+const tokenMinLifetime = time.Minute
+
+// APIToken represents a OneLogin API access token
+type APIToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	Expiration   time.Time `json:"expiration"`
+}
+
+// tokenCache holds the cached API tokens keyed by OneLogin API URL and client ID
+type tokenCache map[string]APIToken
+
+func tokenKey(conf Config) string {
+	return conf.BaseURL + "|" + conf.ClientID
+}
+
+func readTokenCache(homeDir string) tokenCache {
+	cache := tokenCache{}
+	if !readJSONFile(homeDir, tokenCacheFileName, &cache) || cache == nil {
+		return tokenCache{}
+	}
+	return cache
+}
+
+// CachedToken returns the cached OneLogin API token while it's valid. An expired token is refreshed,
+// or replaced by a new token when OneLogin refuses to refresh it, and stored in the token cache.
+func (client *Client) CachedToken(conf Config, homeDir string) (string, error) {
+	token, _, err := client.cachedToken(conf, homeDir)
+	return token, err
+}
+
+// cachedToken returns the OneLogin API token like CachedToken and whether it was reused from the cache
+func (client *Client) cachedToken(conf Config, homeDir string) (string, bool, error) {
+	cache := readTokenCache(homeDir)
+	key := tokenKey(conf)
+	if cached, ok := cache[key]; ok && cached.Expiration.Sub(client.Clock.Now()) > tokenMinLifetime {
+		logger.Info("Using the cached OneLogin API token")
+		return cached.AccessToken, true, nil
+	}
+
+	token, err := client.renewToken(conf, cache[key])
+	if err != nil {
+		return "", false, err
+	}
+	cache[key] = token
+	if err := writeJSONFile(homeDir, tokenCacheFileName, cache); err != nil {
+		// The token is still usable, it's only not reused
+		logger.Warn(err.Error())
+	}
+	return token.AccessToken, false, nil
+}
+
+// CachedSAMLAssertion requests the SAML assertion with the cached OneLogin API token and returns the
+// token used. A reused token OneLogin rejects, for example after the client secret was rotated, is
+// revoked and the request retried once with a new token.
+func (client *Client) CachedSAMLAssertion(conf Config, homeDir string, password string) (SAMLAssertionData,
+	string, error) {
+
+	apiToken, reused, err := client.cachedToken(conf, homeDir)
+	if err != nil {
+		return SAMLAssertionData{}, "", err
+	}
+	samlAssertionData, err := client.SAMLAssertion(conf, password, apiToken)
+	if !reused || !errors.Is(err, ErrAPIToken) {
+		return samlAssertionData, apiToken, err
+	}
+
+	logger.Info("The cached OneLogin API token was rejected, retrying with a new token")
+	if err := client.RevokeToken(conf, homeDir); err != nil {
+		// OneLogin likely refuses to revoke a token it already rejects
+		logger.Warn(err.Error())
+	}
+	if apiToken, _, err = client.cachedToken(conf, homeDir); err != nil {
+		return SAMLAssertionData{}, "", err
+	}
+	samlAssertionData, err = client.SAMLAssertion(conf, password, apiToken)
+	return samlAssertionData, apiToken, err
+}
+
+// renewToken refreshes an expired token, a new token is generated without a refresh token or when
+// the refresh fails
+func (client *Client) renewToken(conf Config, expired APIToken) (APIToken, error) {
+	if expired.RefreshToken != "" {
+		token, err := client.refreshToken(conf, expired)
+		if err == nil {
+			logger.Info("Refreshed the OneLogin API token")
+			return token, nil
+		}
+		logger.Sugar().Infof("Unable to refresh the OneLogin API token: %s", err)
+	}
+	return client.generateToken(conf)
+}
+
+// RevokeToken removes the OneLogin API token from the token cache and revokes it
+func (client *Client) RevokeToken(conf Config, homeDir string) error {
+	cache := readTokenCache(homeDir)
+	key := tokenKey(conf)
+	token, ok := cache[key]
+	if !ok {
+		return nil
+	}
+	delete(cache, key)
+	if err := writeJSONFile(homeDir, tokenCacheFileName, cache); err != nil {
+		return err
+	}
+	if !token.Expiration.After(client.Clock.Now()) {
+		// OneLogin already expired the token
+		return nil
+	}
+	return client.revokeToken(conf, token)
+}
@@ -0,0 +1,139 @@
+package masl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedToken(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".masl"), 0700))
+
+	var grants, revoked []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		grants = append(grants, request["grant_type"])
+		if request["grant_type"] == "refresh_token" {
+			assert.Equal(t, "refresh-1", request["refresh_token"])
+		}
+		n := strconv.Itoa(len(grants))
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"},"data":[{"access_token":"token-` + n +
+			`","refresh_token":"refresh-` + n + `","expires_in":36000}]}`))
+	})
+	mux.HandleFunc("/auth/oauth2/revoke", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "client_id:id,client_secret:secret", r.Header.Get("Authorization"))
+		request := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		revoked = append(revoked, request["access_token"])
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conf := Config{BaseURL: server.URL + "/", ClientID: "id", ClientSecret: "secret"}
+	now := time.Now()
+	client := NewClient()
+	client.Clock = fixedClock(now)
+
+	token, err := client.CachedToken(conf, homeDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	token, err = client.CachedToken(conf, homeDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, []string{"client_credentials"}, grants)
+
+	info, err := os.Stat(maslPath(homeDir, tokenCacheFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// An expired token is refreshed
+	client.Clock = fixedClock(now.Add(10 * time.Hour))
+	token, err = client.CachedToken(conf, homeDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, []string{"client_credentials", "refresh_token"}, grants)
+
+	assert.NoError(t, client.RevokeToken(conf, homeDir))
+	assert.Equal(t, []string{"token-2"}, revoked)
+	assert.Empty(t, readTokenCache(homeDir))
+	assert.NoError(t, client.RevokeToken(conf, homeDir))
+	assert.Len(t, revoked, 1)
+}
+
+func TestCachedSAMLAssertion(t *testing.T) {
+	homeDir, err := ioutil.TempDir("", "masl")
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".masl"), 0700))
+
+	generated := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		generated++
+		n := strconv.Itoa(generated)
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"},"data":[{"access_token":"token-` + n +
+			`","refresh_token":"refresh-` + n + `","expires_in":36000}]}`))
+	})
+	var revoked []string
+	mux.HandleFunc("/auth/oauth2/revoke", func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]string{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		revoked = append(revoked, request["access_token"])
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"}}`))
+	})
+	mux.HandleFunc("/api/2/saml_assertion", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer rejected" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"statusCode":401,"name":"Unauthorized","message":"Authentication Failure"}`))
+			return
+		}
+		request := SAMLAssertionRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.Password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"statusCode":401,"name":"Unauthorized",
+				"message":"Authentication Failed: Invalid user credentials"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":"assertion","message":"Success"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conf := Config{BaseURL: server.URL + "/", ClientID: "id", ClientSecret: "secret", APIVersion: APIVersion2}
+	now := time.Now()
+	client := NewClient()
+	client.Clock = fixedClock(now)
+	assert.NoError(t, writeJSONFile(homeDir, tokenCacheFileName,
+		tokenCache{tokenKey(conf): {AccessToken: "rejected", Expiration: now.Add(10 * time.Hour)}}))
+
+	// The rejected token is revoked and replaced without blaming the password
+	samlAssertionData, token, err := client.CachedSAMLAssertion(conf, homeDir, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "assertion", samlAssertionData.Data)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, []string{"rejected"}, revoked)
+	assert.Equal(t, "token-1", readTokenCache(homeDir)[tokenKey(conf)].AccessToken)
+
+	// A wrong password keeps the cached token
+	_, token, err = client.CachedSAMLAssertion(conf, homeDir, "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, 1, generated)
+	assert.Len(t, revoked, 1)
+	assert.Equal(t, "token-1", readTokenCache(homeDir)[tokenKey(conf)].AccessToken)
+}
@@ -0,0 +1,132 @@
+package masl
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint: gosec, RFC 6238 defaults to HMAC-SHA1
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// SecretOTPSeed is the name of the TOTP seed in the SecretStore
+const SecretOTPSeed = "otp-seed"
+
+// TOTPSettings represents the parameters of a time-based one-time password
+type TOTPSettings struct {
+	Digits    int
+	Period    int
+	Algorithm string
+}
+
+// totpAlgorithms the HMAC hash functions supported by RFC 6238
+var totpAlgorithms = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// TOTPSettingsFor returns the TOTP settings of the config, falling back to the RFC 6238 defaults
+func TOTPSettingsFor(conf Config) TOTPSettings {
+	settings := TOTPSettings{Digits: conf.OTPDigits, Period: conf.OTPPeriod, Algorithm: conf.OTPAlgorithm}
+	if settings.Digits == 0 {
+		settings.Digits = 6
+	}
+	if settings.Period == 0 {
+		settings.Period = 30
+	}
+	if settings.Algorithm == "" {
+		settings.Algorithm = "SHA1"
+	}
+	return settings
+}
+
+// TOTP generates the time-based one-time password (RFC 6238) for a base32 encoded seed
+func TOTP(seed string, t time.Time, settings TOTPSettings) (string, error) {
+	newHash, ok := totpAlgorithms[strings.ToUpper(settings.Algorithm)]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported OTP algorithm [%s]", ErrInvalidConfig, settings.Algorithm)
+	}
+	if settings.Digits < 6 || settings.Digits > 10 || settings.Period <= 0 {
+		return "", fmt.Errorf("%w: invalid OTP digits or period", ErrInvalidConfig)
+	}
+	seed = strings.ToUpper(strings.Replace(strings.TrimSpace(seed), " ", "", -1))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(seed, "="))
+	if err != nil || len(key) == 0 {
+		return "", errors.New("the OTP seed isn't valid base32")
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix()/int64(settings.Period)))
+	mac := hmac.New(newHash, key)
+	mac.Write(counter) // nolint
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	code := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	modulo := uint64(1)
+	for i := 0; i < settings.Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", settings.Digits, code%modulo), nil
+}
+
+// IsTOTPDevice test if an MFA device accepts time-based one-time passwords generated from a seed
+func IsTOTPDevice(device MFADevice) bool {
+	deviceType := strings.ToLower(device.DeviceType)
+	return strings.Contains(deviceType, "google authenticator") || strings.Contains(deviceType, "onelogin protect")
+}
+
+// UseTOTP test if the MFA device is verified with a one-time password generated from the seed.
+// OneLogin Protect accepts both, it only uses push notifications instead when PreferPush is set.
+func UseTOTP(conf Config, device MFADevice) bool {
+	return IsTOTPDevice(device) && !(conf.PreferPush && IsPushDevice(device))
+}
+
+// OTPSeed returns the TOTP seed from the OTPSecretCommand or the secret store. An empty seed is
+// returned when neither has it.
+func OTPSeed(conf Config, store func() (SecretStore, error)) (string, error) {
+	if conf.OTPSecretCommand != "" {
+		return SecretCommand(conf.OTPSecretCommand)
+	}
+	secretStore, err := store()
+	if err != nil {
+		return "", err
+	}
+	seed, err := secretStore.Get(SecretOTPSeed)
+	if errors.Is(err, ErrSecretNotFound) {
+		return "", nil
+	}
+	return seed, err
+}
+
+// VerifyMFATOTP verifies the MFA device with a one-time password generated from the seed. When it's
+// rejected the passwords of the previous and next period are tried, to allow for clock skew.
+func (client *Client) VerifyMFATOTP(conf Config, deviceID int, stateToken string, seed string,
+	apiToken string) (string, error) {
+
+	settings := TOTPSettingsFor(conf)
+	now := client.Clock.Now()
+	period := time.Duration(settings.Period) * time.Second
+
+	var err error
+	for _, skew := range []time.Duration{0, -period, period} {
+		var otp string
+		if otp, err = TOTP(seed, now.Add(skew), settings); err != nil {
+			return "", err
+		}
+		var samlData string
+		samlData, err = client.VerifyMFA(conf, deviceID, stateToken, otp, apiToken)
+		if !errors.Is(err, ErrMFARejected) {
+			return samlData, err
+		}
+		logger.Sugar().Infof("Generated one-time password with a clock skew of %v rejected.", skew)
+	}
+	return "", err
+}
@@ -0,0 +1,98 @@
+package masl
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTP(t *testing.T) {
+	// The test vectors of RFC 6238 appendix B
+	seeds := map[string]string{
+		"SHA1":   base32.StdEncoding.EncodeToString([]byte("12345678901234567890")),
+		"SHA256": base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012")),
+		"SHA512": base32.StdEncoding.EncodeToString(
+			[]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+	tests := []struct {
+		time      int64
+		algorithm string
+		otp       string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, test := range tests {
+		otp, err := TOTP(seeds[test.algorithm], time.Unix(test.time, 0),
+			TOTPSettings{Digits: 8, Period: 30, Algorithm: test.algorithm})
+		assert.NoError(t, err)
+		assert.Equal(t, test.otp, otp, "%s at %d", test.algorithm, test.time)
+	}
+
+	// Seeds are often shown lower case, grouped and without padding
+	otp, err := TOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0), TOTPSettingsFor(Config{}))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", otp)
+
+	_, err = TOTP("not base32!", time.Unix(59, 0), TOTPSettingsFor(Config{}))
+	assert.Error(t, err)
+	_, err = TOTP(seeds["SHA1"], time.Unix(59, 0), TOTPSettings{Digits: 6, Period: 30, Algorithm: "MD5"})
+	assert.Error(t, err)
+}
+
+type fixedClock time.Time
+
+func (clock fixedClock) Now() time.Time { return time.Time(clock) }
+func (fixedClock) Sleep(time.Duration)  {}
+
+func TestVerifyMFATOTPClockSkew(t *testing.T) {
+	seed := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	var otps []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := VerifyMFARequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		otps = append(otps, request.OtpToken)
+
+		response := VerifyMFAResponse{}
+		// The server's clock is a period ahead
+		if request.OtpToken == "081804" {
+			response.Status.Code = 200
+			response.Data = "assertion"
+		} else {
+			response.Status.Code = 401
+			response.Status.Message = "Failed authentication with this factor"
+		}
+		writeJSON(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.Clock = fixedClock(time.Unix(1111111109-30, 0))
+	samlData, err := client.VerifyMFATOTP(Config{BaseURL: server.URL + "/"}, 1, "state", seed, "token")
+	assert.NoError(t, err)
+	assert.Equal(t, "assertion", samlData)
+	assert.Len(t, otps, 3)
+}
+
+func TestUseTOTP(t *testing.T) {
+	protect := MFADevice{DeviceType: "OneLogin Protect"}
+	assert.True(t, UseTOTP(Config{}, protect))
+	assert.False(t, UseTOTP(Config{PreferPush: true}, protect))
+	assert.True(t, UseTOTP(Config{PreferPush: true}, MFADevice{DeviceType: "Google Authenticator"}))
+	assert.False(t, UseTOTP(Config{}, MFADevice{DeviceType: "Yubico YubiKey"}))
+}
@@ -0,0 +1,296 @@
+// Package masl allows other tools to assume an AWS role through OneLogin SAML authentication.
+//
+// A Client covers the individual steps (OneLogin API token generation, SAML assertion, MFA
+// verification, role parsing and assuming the role on AWS) as well as the complete Login flow.
+// All its dependencies can be replaced, which makes it possible to run masl against fake
+// servers.
+package masl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+)
+
+// Config represents the masl config file
+type Config = masl.Config
+
+// Account represents an account entry in the masl config file
+type Account = masl.Account
+
+// Accounts represents the accounts section of the masl config file
+type Accounts = masl.Accounts
+
+// Chain represents a role assumed from another role of an account
+type Chain = masl.Chain
+
+// SAMLAssertionData represents the OneLogin SAML assertion response
+type SAMLAssertionData = masl.SAMLAssertionData
+
+// MFADevice represents an MFA device
+type MFADevice = masl.MFADevice
+
+// SAMLAssertionRole represents a Role which could be assumed on AWS
+type SAMLAssertionRole = masl.SAMLAssertionRole
+
+// SAMLAttributes represents the session attributes in a SAML assertion
+type SAMLAttributes = masl.SAMLAttributes
+
+// MalformedRoleError lists the role attribute values which couldn't be parsed
+type MalformedRoleError = masl.MalformedRoleError
+
+// STSAPI represents the AWS STS operations used by masl
+type STSAPI = masl.STSAPI
+
+// SecretStore represents a place to keep the secrets of masl, like the TOTP seed
+type SecretStore = masl.SecretStore
+
+// STSFactory creates the AWS STS client for a role from its AWS config
+type STSFactory = masl.STSFactory
+
+// Clock represents the source of time used by masl
+type Clock = masl.Clock
+
+// The errors returned by a Client, use errors.Is to test for them
+var (
+	ErrOneLoginAPI        = masl.ErrOneLoginAPI
+	ErrAPIToken           = masl.ErrAPIToken
+	ErrInvalidCredentials = masl.ErrInvalidCredentials
+	ErrAccountLocked      = masl.ErrAccountLocked
+	ErrMFARejected        = masl.ErrMFARejected
+	ErrMFATimeout         = masl.ErrMFATimeout
+	ErrStateTokenExpired  = masl.ErrStateTokenExpired
+	ErrInvalidAssertion   = masl.ErrInvalidAssertion
+	ErrNoRoles            = masl.ErrNoRoles
+	ErrSTSDenied          = masl.ErrSTSDenied
+	ErrFederation         = masl.ErrFederation
+	ErrSecretNotFound     = masl.ErrSecretNotFound
+	// ErrNoPrompter Login requires a Prompter
+	ErrNoPrompter = errors.New("no prompter configured")
+)
+
+// Prompter asks the user for the input required during Login
+type Prompter interface {
+	// Password returns the OneLogin password, it's asked again when OneLogin rejects it
+	Password() (string, error)
+	// SelectMFADevice picks the MFA device to verify the login with
+	SelectMFADevice(devices []MFADevice) (MFADevice, error)
+	// OTP returns the one-time password of the MFA device. An empty one-time password sends a
+	// push notification to devices supporting it. A rejected one-time password is asked again, up to
+	// LoginAttempts times.
+	OTP(device MFADevice) (string, error)
+	// SelectRole picks the role to assume
+	SelectRole(roles []*SAMLAssertionRole) (*SAMLAssertionRole, error)
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to call the OneLogin API
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) { client.api.HTTPClient = httpClient }
+}
+
+// WithSTS sets the factory creating the AWS STS clients used to assume roles. The factory receives
+// the region, STS endpoint and, for chained roles, the credentials of the previous role.
+func WithSTS(newSTS STSFactory) Option {
+	return func(client *Client) { client.api.NewSTS = newSTS }
+}
+
+// WithClock sets the source of time
+func WithClock(clock Clock) Option {
+	return func(client *Client) { client.api.Clock = clock }
+}
+
+// WithSecretStore sets the SecretStore holding the TOTP seed, Login generates the one-time passwords
+// from it (or from the OTPSecretCommand) instead of asking the Prompter. Login fails with
+// ErrSecretNotFound when the seed is missing.
+func WithSecretStore(store SecretStore) Option {
+	return func(client *Client) { client.secrets = store }
+}
+
+// WithPrompter sets the Prompter used by Login
+func WithPrompter(prompter Prompter) Option {
+	return func(client *Client) { client.prompter = prompter }
+}
+
+// Client assumes AWS roles through OneLogin
+type Client struct {
+	conf     Config
+	api      *masl.Client
+	prompter Prompter
+	secrets  SecretStore
+}
+
+// New creates a Client for the given configuration
+func New(conf Config, opts ...Option) *Client {
+	client := &Client{conf: conf, api: masl.NewClient()}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// GenerateToken generates a new OneLogin API access token
+func (client *Client) GenerateToken() (string, error) {
+	return client.api.GenerateToken(client.conf)
+}
+
+// SAMLAssertion requests a SAML assertion for the configured user and app
+func (client *Client) SAMLAssertion(password string, apiToken string) (SAMLAssertionData, error) {
+	return client.api.SAMLAssertion(client.conf, password, apiToken)
+}
+
+// VerifyMFA verifies the one-time password of an MFA device and returns the SAML assertion
+func (client *Client) VerifyMFA(device MFADevice, stateToken string, otp string,
+	apiToken string) (string, error) {
+	return client.api.VerifyMFA(client.conf, device.DeviceID, stateToken, otp, apiToken)
+}
+
+// VerifyMFAPush sends a push notification to an MFA device and waits until it's approved
+func (client *Client) VerifyMFAPush(device MFADevice, samlAssertionData SAMLAssertionData,
+	apiToken string) (string, error) {
+	return client.api.VerifyMFAPush(client.conf, device.DeviceID, samlAssertionData, apiToken)
+}
+
+// ParseSAMLAssertion parses the roles in a SAML assertion, optionally filtered on account IDs
+// and role name. Malformed roles are reported by a *MalformedRoleError next to the valid roles.
+func (client *Client) ParseSAMLAssertion(samlAssertion string, accountFilter []string,
+	role string) ([]*SAMLAssertionRole, error) {
+	return masl.ParseSAMLAssertion(samlAssertion, client.conf.Accounts, accountFilter, role)
+}
+
+// ParseSAMLAttributes parses the session duration, session name, source identity and session tags
+// from a SAML assertion
+func (client *Client) ParseSAMLAttributes(samlAssertion string) (SAMLAttributes, error) {
+	return masl.ParseSAMLAttributes(samlAssertion)
+}
+
+// AssumeRole assumes a role on AWS using the SAML assertion
+func (client *Client) AssumeRole(samlAssertion string,
+	role *SAMLAssertionRole) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return client.api.AssumeRole(client.conf, samlAssertion, masl.SessionDuration(client.conf, role), role)
+}
+
+// ConsoleURL returns a URL to sign in to the AWS Management Console with the role credentials,
+// optionally opening a console path (or URL) in a region
+func (client *Client) ConsoleURL(assertionOutput *sts.AssumeRoleWithSAMLOutput, role *SAMLAssertionRole,
+	destination string, region string) (string, error) {
+	return client.api.ConsoleURL(client.conf, assertionOutput, role, destination, region)
+}
+
+// Login runs the complete flow, asking the Prompter for input, and assumes the selected role
+func (client *Client) Login(accountFilter []string,
+	role string) (*sts.AssumeRoleWithSAMLOutput, *SAMLAssertionRole, error) {
+
+	if client.prompter == nil {
+		return nil, nil, ErrNoPrompter
+	}
+	apiToken, err := client.GenerateToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	samlData, err := client.login(apiToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles, err := client.ParseSAMLAssertion(samlData, accountFilter, role)
+	var malformed *MalformedRoleError
+	if err != nil && !errors.As(err, &malformed) {
+		return nil, nil, err
+	}
+	if len(roles) == 0 {
+		return nil, nil, ErrNoRoles
+	}
+	selected := roles[0]
+	if len(roles) > 1 {
+		if selected, err = client.prompter.SelectRole(roles); err != nil {
+			return nil, nil, err
+		}
+	}
+	assertionOutput, err := client.AssumeRole(samlData, selected)
+	if err != nil {
+		return nil, nil, err
+	}
+	return assertionOutput, selected, nil
+}
+
+// login asks the password and verifies the MFA device, a rejected password or an expired state token
+// starts over up to LoginAttempts times
+func (client *Client) login(apiToken string) (string, error) {
+	attempts := masl.LoginAttempts(client.conf)
+	for attempt := 1; ; attempt++ {
+		password, err := client.prompter.Password()
+		if err != nil {
+			return "", err
+		}
+		samlAssertionData, err := client.SAMLAssertion(password, apiToken)
+		if errors.Is(err, ErrInvalidCredentials) && attempt < attempts {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		samlData, err := client.verify(samlAssertionData, apiToken)
+		if errors.Is(err, ErrStateTokenExpired) && attempt < attempts {
+			continue
+		}
+		return samlData, err
+	}
+}
+
+func (client *Client) verify(samlAssertionData SAMLAssertionData, apiToken string) (string, error) {
+	if !samlAssertionData.MFARequired {
+		return samlAssertionData.Data, nil
+	}
+	if len(samlAssertionData.Devices) == 0 {
+		return "", fmt.Errorf("%w: no MFA devices available", ErrMFARejected)
+	}
+	device := samlAssertionData.Devices[0]
+	if len(samlAssertionData.Devices) > 1 {
+		var err error
+		if device, err = client.prompter.SelectMFADevice(samlAssertionData.Devices); err != nil {
+			return "", err
+		}
+	}
+	if client.totpConfigured() && masl.UseTOTP(client.conf, device) {
+		seed, err := client.otpSeed()
+		if err != nil {
+			return "", err
+		}
+		if seed == "" {
+			return "", fmt.Errorf("%w: no TOTP seed for %s", ErrSecretNotFound, device.DeviceType)
+		}
+		return client.api.VerifyMFATOTP(client.conf, device.DeviceID, samlAssertionData.StateToken, seed,
+			apiToken)
+	}
+	attempts := masl.LoginAttempts(client.conf)
+	for attempt := 1; ; attempt++ {
+		otp, err := client.prompter.OTP(device)
+		if err != nil {
+			return "", err
+		}
+		if otp == "" && masl.IsPushDevice(device) {
+			return client.VerifyMFAPush(device, samlAssertionData, apiToken)
+		}
+		samlData, err := client.VerifyMFA(device, samlAssertionData.StateToken, otp, apiToken)
+		if !errors.Is(err, ErrMFARejected) || attempt >= attempts {
+			return samlData, err
+		}
+	}
+}
+
+// totpConfigured reports whether the one-time passwords are generated, a SecretStore or an
+// OTPSecretCommand provides the TOTP seed
+func (client *Client) totpConfigured() bool {
+	return client.secrets != nil || client.conf.OTPSecretCommand != ""
+}
+
+// otpSeed returns the TOTP seed from the OTPSecretCommand or the SecretStore
+func (client *Client) otpSeed() (string, error) {
+	return masl.OTPSeed(client.conf, func() (SecretStore, error) { return client.secrets, nil })
+}
@@ -0,0 +1,244 @@
+package masl
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/glnds/masl/internal/masl"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAssertion = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"
+ xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">
+ <saml:Assertion>
+  <saml:AttributeStatement>
+   <saml:Attribute Name="https://aws.amazon.com/SAML/Attributes/Role">
+    <saml:AttributeValue>arn:aws:iam::123456789012:role/admin,arn:aws:iam::123456789012:saml-provider/onelogin</saml:AttributeValue>
+    <saml:AttributeValue>arn:aws:iam::210987654321:role/admin,arn:aws:iam::210987654321:saml-provider/onelogin</saml:AttributeValue>
+   </saml:Attribute>
+  </saml:AttributeStatement>
+ </saml:Assertion>
+</samlp:Response>`
+
+type fakeSTS struct {
+	input *sts.AssumeRoleWithSAMLInput
+}
+
+func (fake *fakeSTS) AssumeRoleWithSAML(input *sts.AssumeRoleWithSAMLInput) (*sts.AssumeRoleWithSAMLOutput, error) {
+	fake.input = input
+	return &sts.AssumeRoleWithSAMLOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{Arn: aws.String("arn:aws:sts::123456789012:assumed-role/admin/me")},
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("AKID"),
+			SecretAccessKey: aws.String("SECRET"),
+			SessionToken:    aws.String("TOKEN"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func (fake *fakeSTS) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+// staticSTS returns the same STS client for every role
+func staticSTS(stsClient STSAPI) STSFactory {
+	return func(*aws.Config) STSAPI { return stsClient }
+}
+
+type fakePrompter struct {
+	otp string
+}
+
+func (fakePrompter) Password() (string, error) { return "secret", nil }
+func (fakePrompter) SelectMFADevice(devices []MFADevice) (MFADevice, error) {
+	return devices[0], nil
+}
+func (prompter fakePrompter) OTP(device MFADevice) (string, error) { return prompter.otp, nil }
+func (fakePrompter) SelectRole(roles []*SAMLAssertionRole) (*SAMLAssertionRole, error) {
+	return roles[len(roles)-1], nil
+}
+
+func newOneLogin(t *testing.T, mfa bool) *httptest.Server {
+	saml := b64.StdEncoding.EncodeToString([]byte(testAssertion))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"},"data":[{"access_token":"token"}]}`))
+	})
+	mux.HandleFunc("/api/1/saml_assertion", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bearer:token", r.Header.Get("Authorization"))
+		if mfa {
+			_, _ = w.Write([]byte(`{"status":{"code":200,"message":"MFA is required for this user"},
+				"data":[{"state_token":"state","devices":[{"device_id":1,"device_type":"Yubico YubiKey"}]}]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": map[string]interface{}{"code": 200, "message": "Success"},
+			"data":   saml,
+		})
+	})
+	mux.HandleFunc("/api/1/saml_assertion/verify_factor", func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request["otp_token"] != "123456" {
+			_, _ = w.Write([]byte(`{"status":{"code":401,"message":"Failed authentication with this factor"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": map[string]interface{}{"code": 200, "message": "Success"},
+			"data":   saml,
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestLogin(t *testing.T) {
+	server := newOneLogin(t, false)
+	defer server.Close()
+
+	stsClient := &fakeSTS{}
+	client := New(Config{BaseURL: server.URL + "/", Duration: 3600},
+		WithHTTPClient(server.Client()), WithSTS(staticSTS(stsClient)), WithPrompter(fakePrompter{}))
+
+	output, role, err := client.Login(nil, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID", *output.Credentials.AccessKeyId)
+	assert.Equal(t, "210987654321", role.AccountID)
+	assert.Equal(t, "arn:aws:iam::210987654321:role/admin", *stsClient.input.RoleArn)
+	assert.Equal(t, int64(3600), *stsClient.input.DurationSeconds)
+}
+
+func TestLoginMFA(t *testing.T) {
+	server := newOneLogin(t, true)
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL + "/"}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(fakePrompter{otp: "123456"}))
+	_, role, err := client.Login([]string{"123456789012"}, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012", role.AccountID)
+
+	client = New(Config{BaseURL: server.URL + "/"}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(fakePrompter{otp: "000000"}))
+	_, _, err = client.Login(nil, "")
+	assert.ErrorIs(t, err, ErrMFARejected)
+}
+
+// retryPrompter hands out the one-time passwords in order
+type retryPrompter struct {
+	fakePrompter
+	otps []string
+}
+
+func (prompter *retryPrompter) OTP(device MFADevice) (string, error) {
+	otp := prompter.otps[0]
+	prompter.otps = prompter.otps[1:]
+	return otp, nil
+}
+
+func TestLoginMFARetry(t *testing.T) {
+	server := newOneLogin(t, true)
+	defer server.Close()
+
+	prompter := &retryPrompter{otps: []string{"000000", "123456"}}
+	client := New(Config{BaseURL: server.URL + "/"}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(prompter))
+	_, _, err := client.Login(nil, "")
+	assert.NoError(t, err)
+	assert.Empty(t, prompter.otps)
+
+	prompter = &retryPrompter{otps: []string{"000000", "123456"}}
+	client = New(Config{BaseURL: server.URL + "/", LoginAttempts: 1}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(prompter))
+	_, _, err = client.Login(nil, "")
+	assert.ErrorIs(t, err, ErrMFARejected)
+}
+
+func TestLoginNoRoles(t *testing.T) {
+	server := newOneLogin(t, false)
+	defer server.Close()
+
+	client := New(Config{BaseURL: server.URL + "/"}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(fakePrompter{}))
+	_, _, err := client.Login([]string{"000000000000"}, "")
+	assert.ErrorIs(t, err, ErrNoRoles)
+}
+
+type fixedClock time.Time
+
+func (clock fixedClock) Now() time.Time { return time.Time(clock) }
+func (fixedClock) Sleep(time.Duration)  {}
+
+// mapSecretStore keeps the secrets in memory
+type mapSecretStore map[string]string
+
+func (store mapSecretStore) Get(name string) (string, error) {
+	if value, ok := store[name]; ok {
+		return value, nil
+	}
+	return "", masl.ErrSecretNotFound
+}
+func (store mapSecretStore) Set(name string, value string) error { store[name] = value; return nil }
+func (store mapSecretStore) Delete(name string) error            { delete(store, name); return nil }
+
+func TestLoginTOTP(t *testing.T) {
+	seed := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+	otp, err := masl.TOTP(seed, now, masl.TOTPSettings{Digits: 6, Period: 30, Algorithm: "SHA1"})
+	assert.NoError(t, err)
+
+	saml := b64.StdEncoding.EncodeToString([]byte(testAssertion))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"Success"},"data":[{"access_token":"token"}]}`))
+	})
+	mux.HandleFunc("/api/1/saml_assertion", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":{"code":200,"message":"MFA is required for this user"},
+			"data":[{"state_token":"state","devices":[{"device_id":1,"device_type":"Google Authenticator"}]}]}`))
+	})
+	mux.HandleFunc("/api/1/saml_assertion/verify_factor", func(w http.ResponseWriter, r *http.Request) {
+		request := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request["otp_token"] != otp {
+			_, _ = w.Write([]byte(`{"status":{"code":401,"message":"Failed authentication with this factor"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": map[string]interface{}{"code": 200, "message": "Success"},
+			"data":   saml,
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The prompter isn't asked for a one-time password, the seed comes from the store or the command
+	tests := []struct {
+		conf  Config
+		store SecretStore
+	}{
+		{Config{BaseURL: server.URL + "/"}, mapSecretStore{masl.SecretOTPSeed: seed}},
+		{Config{BaseURL: server.URL + "/", OTPSecretCommand: "echo " + seed}, nil},
+	}
+	for _, test := range tests {
+		client := New(test.conf, WithHTTPClient(server.Client()), WithSTS(staticSTS(&fakeSTS{})),
+			WithPrompter(fakePrompter{otp: "000000"}), WithClock(fixedClock(now)), WithSecretStore(test.store))
+		_, role, err := client.Login([]string{"123456789012"}, "admin")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "123456789012", role.AccountID)
+		}
+	}
+
+	// A missing seed isn't replaced by the prompter's one-time password
+	client := New(Config{BaseURL: server.URL + "/"}, WithHTTPClient(server.Client()),
+		WithSTS(staticSTS(&fakeSTS{})), WithPrompter(fakePrompter{otp: otp}), WithClock(fixedClock(now)),
+		WithSecretStore(mapSecretStore{}))
+	_, _, err = client.Login(nil, "")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+}